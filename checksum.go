@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ---- sha256 der stable url neu berechnen und gegenprüfen ----
+//
+// Nach dem Bump zeigt die url auf ein neues Archiv. Statt dem kopierten sha256
+// blind zu vertrauen, laden wir das Archiv (streamend, mit Grössenlimit), rechnen
+// den SHA-256 selbst und vergleichen mit dem, was upstream angibt:
+// - sha256 Stanza im Upstream .rb
+// - urls.stable.checksum aus der formulae.brew.sh API (nur wenn dort dieselbe url steht)
+// Weicht eine Quelle ab, schlägt das Update fehl; geschrieben wird immer der berechnete Wert.
+
+// defaultMaxArchiveMB ist das Default-Limit für --max-archive-size.
+const defaultMaxArchiveMB = 1024
+
+// archiveClient lädt die Source-Archive; grosse Tarballs brauchen mehr als die 15s des API Clients.
+var archiveClient = &http.Client{Timeout: 10 * time.Minute}
+
+// checksumMismatchError: das geladene Archiv passt nicht zu einer Upstream-Angabe.
+type checksumMismatchError struct {
+	url      string
+	source   string // "upstream formula" oder "formulae.brew.sh API"
+	expected string
+	actual   string
+}
+
+func (e *checksumMismatchError) Error() string {
+	return fmt.Sprintf("sha256 mismatch for %s: %s says %s, downloaded archive is %s", e.url, e.source, e.expected, e.actual)
+}
+
+// stableSHA256 liefert den sha256 der stable Spec in src ("" wenn keiner da).
+func stableSHA256(src string) string {
+	_, _, sum, _ := stableArchive(src)
+	return sum
+}
+
+// stableArchive liest url und sha256 der stable Spec; git: kein Archiv (git url oder tag:).
+// Scheitert der Parser, kommen die Werte aus dem Zeilen-Fallback (erste url/sha256 Zeile im File).
+func stableArchive(src string) (archiveURL string, git bool, sum string, err error) {
+	doc, perr := parseFormula(src)
+	if perr != nil {
+		u, start, end, ok := lineURL(src)
+		if !ok {
+			return "", false, "", fmt.Errorf("stable url missing (line fallback after %v)", perr)
+		}
+		stmt := src[start:end]
+		if m := reLineSHA256.FindStringSubmatch(src); len(m) == 2 {
+			sum = m[1]
+		}
+		git = strings.HasSuffix(u, ".git") || reLineGitUsing.MatchString(stmt) || reLineTag.MatchString(stmt)
+		return u, git, sum, nil
+	}
+
+	stable := doc.stable()
+	urlStanza := findStanza(stable, "url")
+	if urlStanza == nil {
+		return "", false, "", fmt.Errorf("stable url missing")
+	}
+	if s := findStanza(stable, "sha256"); s != nil {
+		sum, _ = s.stringValue()
+	}
+	if isGitURL(urlStanza) || urlStanza.kw("tag") != nil {
+		return "", true, sum, nil
+	}
+	archiveURL, ok := urlStanza.stringValue()
+	if !ok {
+		return "", false, "", fmt.Errorf("stable url is not a plain string literal")
+	}
+	return archiveURL, false, sum, nil
+}
+
+// verifyStableSHA256 rechnet den sha256 der stable url in src nach und schreibt ihn in src.
+//
+// - upstreamRB: das Upstream .rb (Quelle des erwarteten sha256)
+// - upName: upstream Formula Name für den API Gegencheck
+// - maxBytes: Archive grösser als das brechen den Download ab
+//
+// Git urls (kein sha256) bleiben unverändert. Rückgabe: neuer Inhalt plus berechneter sha256 ("" bei git).
+func verifyStableSHA256(ctx context.Context, client *http.Client, src, upstreamRB, upName string, maxBytes int64) (string, string, error) {
+	archiveURL, git, _, err := stableArchive(src)
+	if err != nil {
+		return "", "", err
+	}
+	if git {
+		return src, "", nil
+	}
+	if !strings.HasPrefix(archiveURL, "https://") && !strings.HasPrefix(archiveURL, "http://") {
+		return "", "", fmt.Errorf("cannot download stable url %s (only http/https)", archiveURL)
+	}
+
+	// 1) Archiv laden und hashen
+	sum, err := downloadSHA256(ctx, archiveClient, archiveURL, maxBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 2) Gegencheck gegen das Upstream .rb (wenn es dieselbe url hat)
+	if upURL, _, want, err := stableArchive(upstreamRB); err == nil && upURL == archiveURL && want != "" && !strings.EqualFold(want, sum) {
+		return "", "", &checksumMismatchError{url: archiveURL, source: "upstream formula", expected: want, actual: sum}
+	}
+
+	// 3) Gegencheck gegen die API; nicht erreichbar oder andere url -> nur Hinweis
+	apiURL, apiSum, err := fetchUpstreamChecksum(ctx, client, upName)
+	switch {
+	case err != nil:
+		fmt.Fprintf(updateOut, "Checksum: API cross-check skipped (%v)\n", err)
+	case apiURL == archiveURL && apiSum != "" && !strings.EqualFold(apiSum, sum):
+		return "", "", &checksumMismatchError{url: archiveURL, source: "formulae.brew.sh API", expected: apiSum, actual: sum}
+	}
+
+	// 4) Berechneten Wert ins File schreiben (ersetzen oder nach der url einfügen)
+	out, err := setStableSHA256(src, sum)
+	if err != nil {
+		return "", "", err
+	}
+	return out, sum, nil
+}
+
+// downloadSHA256 lädt url streamend und liefert den hex SHA-256.
+// Mehr als maxBytes (bzw. ein zu grosser Content-Length Header) ist ein Fehler.
+func downloadSHA256(ctx context.Context, client *http.Client, url string, maxBytes int64) (string, error) {
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &httpStatusError{what: "archive", url: url, status: resp.StatusCode}
+	}
+	if resp.ContentLength > maxBytes {
+		return "", fmt.Errorf("archive %s is %d bytes, limit is %d (see --max-archive-size)", url, resp.ContentLength, maxBytes)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", url, err)
+	}
+	if n > maxBytes {
+		return "", fmt.Errorf("archive %s exceeds limit of %d bytes (see --max-archive-size)", url, maxBytes)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetchUpstreamChecksum liest url + sha256 der stable Spec aus der formulae.brew.sh API.
+// Formulae aus externen Taps (404) liefern "" ohne Fehler.
+func fetchUpstreamChecksum(ctx context.Context, client *http.Client, formula string) (url, checksum string, err error) {
+	apiURL := endpoints.formulaURL(formula)
+	resp, err := httpGet(ctx, client, apiURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", "", nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", "", &httpStatusError{what: "upstream", url: apiURL, status: resp.StatusCode}
+	}
+
+	var data formulaAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", "", err
+	}
+	return data.URLs.Stable.URL, strings.TrimSpace(data.URLs.Stable.Checksum), nil
+}
+
+// setStableSHA256 setzt den sha256 der stable Spec auf sum (ersetzt oder fügt nach der url ein).
+// Scheitert der Parser, gilt die erste sha256 bzw. url Zeile im File (Zeilen-Fallback).
+func setStableSHA256(src, sum string) (string, error) {
+	doc, err := parseFormula(src)
+	if err != nil {
+		if loc := reLineSHA256.FindStringSubmatchIndex(src); loc != nil {
+			return applyEdits(src, []textEdit{{loc[2], loc[3], sum}}), nil
+		}
+		_, start, end, ok := lineURL(src)
+		if !ok {
+			return "", fmt.Errorf("stable url missing (line fallback after %v)", err)
+		}
+		indent := lineIndent(src, start)
+		return applyEdits(src, []textEdit{{end, end, indent + "sha256 " + rbQuote(sum) + "\n"}}), nil
+	}
+	stable := doc.stable()
+	if s := findStanza(stable, "sha256"); s != nil {
+		arg := s.first()
+		if arg == nil || len(arg.toks) != 1 {
+			return "", fmt.Errorf("sha256 is not a plain string literal")
+		}
+		tok := arg.toks[0]
+		return applyEdits(src, []textEdit{{tok.start, tok.end, rbQuote(sum)}}), nil
+	}
+	u := findStanza(stable, "url")
+	if u == nil {
+		return "", fmt.Errorf("stable url missing")
+	}
+	indent := lineIndent(src, u.node.start)
+	at := lineEnd(src, u.node.end)
+	return applyEdits(src, []textEdit{{at, at, "\n" + indent + "sha256 " + rbQuote(sum)}}), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ---- Config Datei (--config / TAP_AUDIT_CONFIG, JSON) ----
+//
+// Alles optional; was fehlt, bleibt beim eingebauten Verhalten:
+//
+//	{
+//	  "taps":          [ ... ],                                  // siehe taps.go; ohne taps gilt TAP_URL / --tap-path
+//	  "overrides":     {"gov-shebang-probe": "scriptisto"},      // privater Name -> upstream Name
+//	  "cask_overrides": {"gov-chrome": "google-chrome"},         // dasselbe für Casks
+//	  "external_taps": {"sdkman-cli": "https://raw.githubusercontent.com/..."}, // upstream Name -> raw .rb
+//	  "ignore":        ["gov-internal-*", "corp/corp-legacy"],   // Globs, optional mit "<tap>/"
+//	  "prefix_rules":  {"gov-py-": "python-"},                   // Prefix ersetzen statt nur entfernen
+//	  "upstream":      {"api": "https://brew-mirror.corp/api"}   // Basis-URLs, siehe endpoints.go
+//	}
+//
+// overrides und external_taps werden mit den eingebauten Maps (upstream.go) zusammengeführt;
+// bei gleichem Key gewinnt die Config.
+//
+// Bewusst nur JSON: die Datei ist klein, encoding/json kann DisallowUnknownFields (Tippfehler
+// fallen auf) und es braucht keine YAML/TOML Dependency.
+
+// auditConfig ist der Inhalt der --config Datei.
+type auditConfig struct {
+	Taps          []tapConfig       `json:"taps"`
+	Overrides     map[string]string `json:"overrides"`
+	CaskOverrides map[string]string `json:"cask_overrides"`
+	ExternalTaps  map[string]string `json:"external_taps"`
+	Ignore        []string          `json:"ignore"`
+	PrefixRules   map[string]string `json:"prefix_rules"`
+	Upstream      upstreamEndpoints `json:"upstream"`
+}
+
+// reTapName: Tap-Namen landen in Pfaden (.cache/taps/<name>) und Branch/Report-Labels.
+var reTapName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// loadAuditConfig liest und validiert die Config. Unbekannte Felder sind ein Fehler
+// (Tippfehler wie "prefx" sollen nicht still ignoriert werden).
+func loadAuditConfig(path string) (auditConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return auditConfig{}, fmt.Errorf("config: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var cfg auditConfig
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return auditConfig{}, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return auditConfig{}, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate prüft alle Abschnitte und meldet den ersten Fehler mit Pfad zum Eintrag,
+// z.B. `overrides["gov-foo"]: upstream name must not be empty`.
+func (c auditConfig) validate() error {
+	seen := map[string]bool{}
+	for i, t := range c.Taps {
+		where := fmt.Sprintf("taps[%d]", i)
+		if t.Name != "" {
+			where += " (" + t.Name + ")"
+		}
+		switch {
+		case !reTapName.MatchString(t.Name):
+			return fmt.Errorf("%s: name must match %s", where, reTapName)
+		case seen[t.Name]:
+			return fmt.Errorf("%s: duplicate tap name", where)
+		case (t.URL == "") == (t.Path == ""):
+			return fmt.Errorf("%s: set exactly one of url or path", where)
+		case t.Path != "" && t.Branch != "":
+			return fmt.Errorf("%s: branch only applies to url taps", where)
+		}
+		seen[t.Name] = true
+	}
+
+	if err := validateOverrides("overrides", "formula", c.Overrides); err != nil {
+		return err
+	}
+	if err := validateOverrides("cask_overrides", "cask", c.CaskOverrides); err != nil {
+		return err
+	}
+
+	for _, k := range sortedKeys(c.ExternalTaps) {
+		v := c.ExternalTaps[k]
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("external_taps: empty formula name")
+		}
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("external_taps[%q]: %q is not an http(s) URL", k, v)
+		}
+	}
+
+	for i, pattern := range c.Ignore {
+		tapName, glob, scoped := strings.Cut(pattern, "/")
+		if !scoped {
+			glob = tapName
+		}
+		if glob == "" {
+			return fmt.Errorf("ignore[%d]: empty pattern", i)
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("ignore[%d]: bad pattern %q: %w", i, pattern, err)
+		}
+		if scoped && len(c.Taps) > 0 && !seen[tapName] {
+			return fmt.Errorf("ignore[%d]: unknown tap %q in %q", i, tapName, pattern)
+		}
+	}
+
+	for _, k := range sortedKeys(c.PrefixRules) {
+		if k == "" {
+			return fmt.Errorf("prefix_rules: empty prefix")
+		}
+		if strings.ContainsAny(c.PrefixRules[k], "/ ") {
+			return fmt.Errorf("prefix_rules[%q]: replacement %q contains '/' or a space", k, c.PrefixRules[k])
+		}
+	}
+	return c.Upstream.validate("upstream.")
+}
+
+// validateOverrides prüft eine Map privater Name -> upstream Name (overrides, cask_overrides).
+func validateOverrides(section, what string, m map[string]string) error {
+	for _, k := range sortedKeys(m) {
+		v := m[k]
+		switch {
+		case strings.TrimSpace(k) == "":
+			return fmt.Errorf("%s: empty private name", section)
+		case strings.TrimSpace(v) == "":
+			return fmt.Errorf("%s[%q]: upstream name must not be empty", section, k)
+		case strings.ContainsAny(v, "/ "):
+			return fmt.Errorf("%s[%q]: %q is not a %s name", section, k, v, what)
+		}
+	}
+	return nil
+}
+
+// nameRules führt overrides / external_taps mit den eingebauten Maps zusammen (Kopien, die
+// Defaults bleiben unverändert) und sortiert prefix_rules. Ohne Config: nur die Defaults.
+func (c auditConfig) nameRules() nameRules {
+	r := nameRules{
+		overrides:     mergeMaps(defaultOverrides, c.Overrides),
+		caskOverrides: mergeMaps(nil, c.CaskOverrides),
+		externalTaps:  mergeMaps(defaultExternalTaps, c.ExternalTaps),
+	}
+
+	// längster Prefix zuerst, damit "gov-py-" vor "gov-" greift
+	for _, k := range sortedKeys(c.PrefixRules) {
+		r.prefixRules = append(r.prefixRules, prefixRule{prefix: k, replace: c.PrefixRules[k]})
+	}
+	sort.SliceStable(r.prefixRules, func(i, j int) bool { return len(r.prefixRules[i].prefix) > len(r.prefixRules[j].prefix) })
+	return r
+}
+
+// mergeMaps liefert eine neue Map mit base, überschrieben durch extra.
+func mergeMaps(base, extra map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+// sortedKeys liefert die Keys einer Map sortiert (stabile Fehlermeldungen).
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// ---- Credentials für HTTPS Git Hosts (Clone/Pull/Push und Provider API; SSH siehe ssh_auth.go) ----
+//
+// resolveCredential probiert der Reihe nach (erster Treffer gewinnt):
+// 1) TAP_TOKEN_<HOST> (+ optional TAP_USER_<HOST>), z.B. TAP_TOKEN_GITHUB_CORP_EXAMPLE_COM
+// 2) Provider-Env: BITBUCKET_USER/BITBUCKET_TOKEN, GITHUB_TOKEN/GH_TOKEN, GITLAB_TOKEN
+//    (nur für bitbucket.org/github.com/gitlab.com oder mit explizitem --provider)
+// 3) user:password direkt in der URL
+// 4) ~/.netrc (bzw. $NETRC), Eintrag "machine <host>" oder "default"
+// 5) `git credential fill` (nutzt die konfigurierten Credential Helper, ohne Prompt)
+// Nichts gefunden -> anonym (öffentliche Repos).
+
+// credential ist ein Username/Token Paar plus Herkunft (nur für Ausgaben, nie das Token).
+type credential struct {
+	username string
+	password string // Token, App Password oder Passwort
+	source   string // z.B. "env TAP_TOKEN_GITHUB_COM", "netrc", "git credential helper"
+}
+
+// gitAuth liefert die go-git Auth für HTTPS; nil (untyped) = anonym.
+func (c *credential) gitAuth() transport.AuthMethod {
+	if c == nil {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: c.username, Password: c.password}
+}
+
+// basicAuthHeader baut den Authorization Header für REST APIs mit BasicAuth.
+func basicAuthHeader(c *credential) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password))
+}
+
+// providerEnvHosts sind die Hosts, an die die Provider-Env Tokens ohne --provider gehen dürfen.
+// Sonst ist der Provider aus dem Host-Namen nur geraten (z.B. "github" in github.corp.example.com)
+// und ein GITHUB_TOKEN für github.com hat dort nichts verloren.
+var providerEnvHosts = map[string]bool{
+	"bitbucket.org": true,
+	"github.com":    true,
+	"gitlab.com":    true,
+}
+
+// resolveCredential sucht Credentials für rawURL. p darf nil sein (unbekannter Host).
+// explicit: Provider per --provider (bzw. Tap-Config) gesetzt statt aus dem Host erkannt.
+// Bei SSH URLs sind die Credentials nur für die Provider API (--pr), git selbst nutzt sshAuth.
+func resolveCredential(ctx context.Context, rawURL string, p gitProvider, explicit bool) *credential {
+	u, err := parseRemoteURL(rawURL)
+	if err != nil || u.Scheme == "file" {
+		return nil
+	}
+	host := u.Hostname()
+	defaultUser := "git"
+	if p != nil {
+		defaultUser = p.defaultUser()
+	}
+
+	// 1) Token pro Host
+	key := hostEnvKey(host)
+	if tok := os.Getenv("TAP_TOKEN_" + key); tok != "" {
+		user := os.Getenv("TAP_USER_" + key)
+		if user == "" {
+			user = defaultUser
+		}
+		return &credential{username: user, password: tok, source: "env TAP_TOKEN_" + key}
+	}
+
+	// 2) Provider-spezifische Env Variablen (andere Hosts: TAP_TOKEN_<HOST>)
+	if p != nil && (explicit || providerEnvHosts[strings.ToLower(host)]) {
+		if c := p.envCredential(); c != nil {
+			return c
+		}
+	}
+
+	// 3) Credentials in der URL (nur HTTPS; bei ssh ist der User kein API Login)
+	if pw, ok := u.User.Password(); ok && pw != "" {
+		return &credential{username: u.User.Username(), password: pw, source: "url"}
+	}
+
+	// 4) netrc, 5) git credential helper
+	c := netrcCredential(host)
+	if c == nil {
+		c = gitCredentialFill(ctx, u)
+	}
+	if c != nil && c.username == "" {
+		c.username = defaultUser
+	}
+	return c
+}
+
+// hostEnvKey macht aus einem Host den Teil des Env-Namens: "github.corp.example.com" -> "GITHUB_CORP_EXAMPLE_COM".
+func hostEnvKey(host string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(host) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// netrcCredential liest $NETRC bzw. ~/.netrc. Ein "machine <host>" Eintrag geht vor "default".
+// Fehlt die Datei oder passt nichts, ist das Ergebnis nil.
+func netrcCredential(host string) *credential {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	// netrc ist eine Folge von Token-Paaren; "machine"/"default" startet einen neuen Eintrag
+	type entry struct{ login, password string }
+	var (
+		match, fallback *entry
+		cur             *entry
+	)
+	fields := strings.Fields(string(data))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			cur = nil
+			if i+1 < len(fields) {
+				i++
+				if strings.EqualFold(fields[i], host) && match == nil {
+					match = &entry{}
+					cur = match
+				}
+			}
+		case "default":
+			cur = nil
+			if fallback == nil {
+				fallback = &entry{}
+				cur = fallback
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				break
+			}
+			i++
+			if cur == nil {
+				continue
+			}
+			if fields[i-1] == "login" {
+				cur.login = fields[i]
+			} else if fields[i-1] == "password" {
+				cur.password = fields[i]
+			}
+		case "macdef":
+			// Makro-Definitionen laufen bis zur nächsten Leerzeile; für uns irrelevant
+			cur = nil
+		}
+	}
+
+	for _, e := range []*entry{match, fallback} {
+		if e != nil && e.password != "" {
+			return &credential{username: e.login, password: e.password, source: "netrc " + path}
+		}
+	}
+	return nil
+}
+
+// gitCredentialFill fragt die konfigurierten git Credential Helper (osxkeychain, manager, store, ...).
+// Prompts sind abgeschaltet; ohne git Binary oder ohne Treffer ist das Ergebnis nil.
+func gitCredentialFill(ctx context.Context, u *url.URL) *credential {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	// SSH URL: nach dem HTTPS Token für denselben Host fragen (für die API)
+	protocol, host := u.Scheme, u.Host
+	if protocol != "http" && protocol != "https" {
+		protocol, host = "https", u.Hostname()
+	}
+	cmd.Stdin = strings.NewReader("protocol=" + protocol + "\nhost=" + host + "\npath=" + strings.TrimPrefix(u.Path, "/") + "\n\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	c := &credential{source: "git credential helper"}
+	sc := bufio.NewScanner(strings.NewReader(string(out)))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "username":
+			c.username = v
+		case "password":
+			c.password = v
+		}
+	}
+	if c.password == "" {
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---- Unified Diff für Dry-Run Vorschau und .patch Files ----
+//
+// Bewusst simpel: zeilenbasiertes LCS (Formula-Files haben ein paar hundert Zeilen),
+// daraus Hunks im `diff -u` Format mit diffContext Kontextzeilen.
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte   // ' ' gleich, '-' entfernt, '+' hinzugefügt
+	line string // inkl. "\n" (ausser bei der letzten Zeile ohne Newline)
+}
+
+// splitLines teilt s in Zeilen und behält das "\n" am Ende jeder Zeile.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines berechnet die Edit-Sequenz von a nach b über die längste gemeinsame Teilfolge.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] = Länge der LCS von a[i:] und b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff liefert den Diff von oldText nach newText im `diff -u` Format.
+// Bei identischem Inhalt ist das Ergebnis "".
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	// Indizes der geänderten Ops; keine Änderung -> kein Diff
+	var changed []int
+	for k, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, k)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Liegen höchstens 2*diffContext gleiche Zeilen zwischen zwei Änderungen, kommen sie
+	// in denselben Hunk (wie diff -u: die Kontexte berühren sich)
+	for c := 0; c < len(changed); {
+		start := max(changed[c]-diffContext, 0)
+		end := changed[c]
+		for c < len(changed) && changed[c] <= end+2*diffContext+1 {
+			end = changed[c]
+			c++
+		}
+		end = min(end+diffContext, len(ops)-1)
+		writeHunk(&b, ops, start, end)
+	}
+	return b.String()
+}
+
+// writeHunk schreibt ops[start..end] als einen @@ Hunk.
+func writeHunk(b *strings.Builder, ops []diffOp, start, end int) {
+	// Zeilennummern (1-basiert) des Hunk-Anfangs in alt/neu
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, op := range ops[start : end+1] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	// diff -u Konvention: leerer Bereich zeigt auf die Zeile davor
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, op := range ops[start : end+1] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// colorizeDiff färbt einen Unified Diff für das Terminal (rot/grün/cyan).
+func colorizeDiff(diff string) string {
+	const (
+		red   = "\x1b[31m"
+		green = "\x1b[32m"
+		cyan  = "\x1b[36m"
+		bold  = "\x1b[1m"
+		reset = "\x1b[0m"
+	)
+	var b strings.Builder
+	for _, line := range splitLines(diff) {
+		text := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ "):
+			b.WriteString(bold + text + reset)
+		case strings.HasPrefix(text, "@@"):
+			b.WriteString(cyan + text + reset)
+		case strings.HasPrefix(text, "-"):
+			b.WriteString(red + text + reset)
+		case strings.HasPrefix(text, "+"):
+			b.WriteString(green + text + reset)
+		default:
+			b.WriteString(text)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// isTerminal: Farbe nur, wenn w ein TTY ist und NO_COLOR nicht gesetzt ist.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// repoRelPath liefert p relativ zum Git-Root (nächster Ordner mit .git),
+// damit .patch Files mit `git apply` im Tap funktionieren. Ohne Git-Root: p unverändert.
+func repoRelPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if ok, _ := pathExists(filepath.Join(dir, ".git")); ok {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				return filepath.ToSlash(rel)
+			}
+			return p
+		}
+		if filepath.Dir(dir) == dir {
+			return p
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestUnifiedDiff vergleicht mit der Ausgabe von `diff -u` (Hunk-Grenzen, leere Bereiche, fehlendes Newline).
+func TestUnifiedDiff(t *testing.T) {
+	// numbered liefert "l1\n" bis "l<n>\n"; repl ersetzt einzelne Zeilen (1-basiert)
+	numbered := func(n int, repl map[int]string) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			if r, ok := repl[i]; ok {
+				b.WriteString(r + "\n")
+				continue
+			}
+			fmt.Fprintf(&b, "l%d\n", i)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     string // ohne die ---/+++ Kopfzeilen
+	}{
+		{
+			name: "identical",
+			old:  numbered(5, nil),
+			new:  numbered(5, nil),
+		},
+		{
+			name: "one change with context",
+			old:  numbered(10, nil),
+			new:  numbered(10, map[int]string{5: "x"}),
+			want: "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+x\n l6\n l7\n l8\n",
+		},
+		{
+			name: "changes 6 lines apart share a hunk",
+			old:  numbered(30, nil),
+			new:  numbered(30, map[int]string{5: "x", 12: "y"}),
+			want: "@@ -2,14 +2,14 @@\n l2\n l3\n l4\n-l5\n+x\n l6\n l7\n l8\n l9\n l10\n l11\n-l12\n+y\n l13\n l14\n l15\n",
+		},
+		{
+			name: "changes 7 lines apart get two hunks",
+			old:  numbered(30, nil),
+			new:  numbered(30, map[int]string{5: "x", 13: "y"}),
+			want: "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+x\n l6\n l7\n l8\n" +
+				"@@ -10,7 +10,7 @@\n l10\n l11\n l12\n-l13\n+y\n l14\n l15\n l16\n",
+		},
+		{
+			name: "no newline at end of file",
+			old:  "a\nb",
+			new:  "a\nc",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name: "newline added at end",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "empty old",
+			old:  "",
+			new:  "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "empty new",
+			old:  "a\nb\n",
+			new:  "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "insert at the top",
+			old:  "b\n",
+			new:  "a\nb\n",
+			want: "@@ -1 +1,2 @@\n+a\n b\n",
+		},
+		{
+			name: "pure insert in the middle",
+			old:  numbered(10, nil),
+			new:  strings.Replace(numbered(10, nil), "l5\n", "l5\nnew\n", 1),
+			want: "@@ -3,6 +3,7 @@\n l3\n l4\n l5\n+new\n l6\n l7\n l8\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- a\n+++ b\n" + want
+			}
+			if got := unifiedDiff("a", "b", tt.old, tt.new); got != want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
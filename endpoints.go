@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ---- Upstream Endpoints (interner Mirror, Proxy, lokaler httptest Server) ----
+//
+// Alle Upstream URLs werden aus drei Basen gebaut:
+// - api:        formulae.brew.sh API (formula.json, cask.json, formula/<name>.json, cask/<token>.json)
+// - core_raw:   homebrew-core Repo für die .rb Files (Formula/<x>/<name>.rb)
+// - github_raw: ersetzt https://raw.githubusercontent.com in den URLs externer Taps
+//
+// Reihenfolge: Flag / Env > Config ("upstream") > Default.
+
+const (
+	defaultUpstreamAPI       = "https://formulae.brew.sh/api"
+	defaultUpstreamCoreRaw   = "https://raw.githubusercontent.com/Homebrew/homebrew-core/refs/heads/master"
+	defaultUpstreamGitHubRaw = "https://raw.githubusercontent.com"
+)
+
+// upstreamEndpoints sind die Basis-URLs (ohne / am Ende).
+type upstreamEndpoints struct {
+	API       string `json:"api,omitempty"`
+	CoreRaw   string `json:"core_raw,omitempty"`
+	GitHubRaw string `json:"github_raw,omitempty"`
+}
+
+// endpoints gilt für den ganzen Run; wird beim Start einmal gesetzt (main.go), danach nur gelesen.
+var endpoints = upstreamEndpoints{
+	API:       defaultUpstreamAPI,
+	CoreRaw:   defaultUpstreamCoreRaw,
+	GitHubRaw: defaultUpstreamGitHubRaw,
+}
+
+// merge übernimmt alle nicht-leeren Felder aus o.
+func (e *upstreamEndpoints) merge(o upstreamEndpoints) {
+	if o.API != "" {
+		e.API = strings.TrimRight(o.API, "/")
+	}
+	if o.CoreRaw != "" {
+		e.CoreRaw = strings.TrimRight(o.CoreRaw, "/")
+	}
+	if o.GitHubRaw != "" {
+		e.GitHubRaw = strings.TrimRight(o.GitHubRaw, "/")
+	}
+}
+
+// validate prüft, dass alle gesetzten Felder http(s) URLs sind; where ist das Prefix der Fehlermeldung.
+func (e upstreamEndpoints) validate(where string) error {
+	for _, f := range []struct{ name, value string }{
+		{"api", e.API}, {"core_raw", e.CoreRaw}, {"github_raw", e.GitHubRaw},
+	} {
+		if f.value == "" {
+			continue
+		}
+		u, err := url.Parse(f.value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%s%s: %q is not an http(s) URL", where, f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// isDefault: keine Abweichung vom öffentlichen Homebrew (für die Ausgabe beim Start).
+func (e upstreamEndpoints) isDefault() bool {
+	return e.API == defaultUpstreamAPI && e.CoreRaw == defaultUpstreamCoreRaw && e.GitHubRaw == defaultUpstreamGitHubRaw
+}
+
+// formulaIndexURL / caskIndexURL sind die Bulk-Exporte (upstream_index.go).
+func (e upstreamEndpoints) formulaIndexURL() string { return e.API + "/formula.json" }
+func (e upstreamEndpoints) caskIndexURL() string    { return e.API + "/cask.json" }
+
+// formulaURL ist der Einzel-Lookup einer Formula in der API.
+func (e upstreamEndpoints) formulaURL(name string) string {
+	return e.API + "/formula/" + name + ".json"
+}
+
+// caskURL ist der Einzel-Lookup eines Casks in der API.
+func (e upstreamEndpoints) caskURL(token string) string {
+	return e.API + "/cask/" + token + ".json"
+}
+
+// coreRawURL baut die Raw-URL für homebrew-core.
+// homebrew-core Struktur: Formula/<first-letter>/<name>.rb
+func (e upstreamEndpoints) coreRawURL(name string) string {
+	return e.CoreRaw + "/Formula/" + name[:1] + "/" + name + ".rb"
+}
+
+// externalURL lenkt raw.githubusercontent.com URLs externer Taps auf GitHubRaw um; andere URLs bleiben.
+func (e upstreamEndpoints) externalURL(raw string) string {
+	if rest, ok := strings.CutPrefix(raw, defaultUpstreamGitHubRaw+"/"); ok {
+		return e.GitHubRaw + "/" + rest
+	}
+	return raw
+}
@@ -46,3 +46,13 @@ func loadDotEnv(path string) error {
 	}
 	return sc.Err()
 }
+
+// firstEnv liefert den Wert der ersten gesetzten (nicht leeren) Env-Variable aus names.
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport" // Auth-Fehler von clone/pull/push erkennen
+)
+
+// ---- Fehlerarten und Exit Codes ----
+//
+// Fatale Fehler in run() sind *runError mit einer Art (config, auth, git, ...).
+// Jede Art hat einen eigenen Exit Code, damit CI "behind" (2) von kaputter Config,
+// fehlenden Credentials oder einem nicht erreichbaren Upstream unterscheiden kann.
+// Die Tabelle steht auch im README; Codes nie umnummerieren, nur neue anhängen.
+
+const (
+	exitOK          = 0   // alles aktuell bzw. alle Updates ok
+	exitInternal    = 1   // unerwarteter Fehler (z.B. Report nicht schreibbar)
+	exitBehind      = 2   // Audit: mindestens ein Eintrag hinter upstream
+	exitConfig      = 3   // Flags, Env, .env, --config
+	exitGit         = 4   // Git Host: Auth, clone/pull, commit, push, Pull Request
+	exitUpstream    = 5   // Upstream Lookups fehlgeschlagen (Report unzuverlässig)
+	exitParse       = 6   // Tap nicht lesbar (Formula/Casks Verzeichnis)
+	exitUpdate      = 7   // --update: mindestens ein Update fehlgeschlagen oder unbekannter Name
+	exitTimeout     = 124 // --timeout abgelaufen (wie coreutils timeout)
+	exitInterrupted = 130 // Ctrl-C / SIGTERM (128 + SIGINT)
+)
+
+// errorKind ist die Art eines fatalen Fehlers.
+type errorKind int
+
+const (
+	errConfig errorKind = iota + 1
+	errAuth
+	errGit
+	errUpstream
+	errParse
+	errUpdate
+)
+
+// String ist das Prefix der Fehlermeldung, z.B. "config error: ...".
+func (k errorKind) String() string {
+	switch k {
+	case errConfig:
+		return "config"
+	case errAuth:
+		return "auth"
+	case errGit:
+		return "git"
+	case errUpstream:
+		return "upstream"
+	case errParse:
+		return "parse"
+	case errUpdate:
+		return "update"
+	}
+	return "internal"
+}
+
+// exitCode: auth und git teilen sich 4 (beides ein Problem mit dem Git Host).
+func (k errorKind) exitCode() int {
+	switch k {
+	case errConfig:
+		return exitConfig
+	case errAuth, errGit:
+		return exitGit
+	case errUpstream:
+		return exitUpstream
+	case errParse:
+		return exitParse
+	case errUpdate:
+		return exitUpdate
+	}
+	return exitInternal
+}
+
+// runError ist ein fataler Fehler mit Art; err bleibt für errors.Is / errors.As erhalten.
+type runError struct {
+	kind errorKind
+	err  error
+}
+
+func (e *runError) Error() string { return e.err.Error() }
+func (e *runError) Unwrap() error { return e.err }
+
+// newError ordnet err einer Art zu; ist err schon ein *runError, bleibt dessen Art.
+func newError(k errorKind, err error) error {
+	var re *runError
+	if err == nil || errors.As(err, &re) {
+		return err
+	}
+	return &runError{kind: k, err: err}
+}
+
+// errorf ist fmt.Errorf mit Art.
+func errorf(k errorKind, format string, args ...any) error {
+	return &runError{kind: k, err: fmt.Errorf(format, args...)}
+}
+
+// gitError: Auth-Fehler des Git Hosts (401/403 bei Git oder Provider API, fehlende Credentials)
+// sind auth, der Rest git.
+func gitError(err error) error {
+	var statusErr *httpStatusError
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) ||
+		(errors.As(err, &statusErr) && (statusErr.status == http.StatusUnauthorized || statusErr.status == http.StatusForbidden)) {
+		return newError(errAuth, err)
+	}
+	return newError(errGit, err)
+}
+
+// fail gibt err auf stderr aus und liefert den passenden Exit Code.
+// Nach Ctrl-C / --timeout zählt der Abbruch, nicht der Folgefehler.
+func fail(ctx context.Context, err error) int {
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", abortReason(ctx), err)
+		return abortExitCode(ctx)
+	}
+	var re *runError
+	if errors.As(err, &re) {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", re.kind, err)
+		return re.kind.exitCode()
+	}
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	return exitInternal
+}
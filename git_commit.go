@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ---- --commit: aktualisierte Formulae im Mirror auf einen eigenen Branch committen ----
+//
+// Ablauf (commitUpdates):
+// 1) Mirror öffnen, aktuellen Branch merken (main/master)
+// 2) Neuen Branch audit/bump-... ab HEAD anlegen (Worktree-Änderungen bleiben erhalten)
+// 3) Geänderte Files stagen und mit generierter Message committen
+// 4) Zurück auf den ursprünglichen Branch, damit der nächste Pull sauber läuft
+
+// bumpedFormula ist eine Formula, die im Update-Lauf wirklich geändert wurde.
+type bumpedFormula struct {
+	tap         *loadedTap // Tap, in dessen Verzeichnis das File liegt
+	privateName string
+	upstream    string // upstream Name, z.B. "abseil"
+	path        string // Pfad im Mirror (wie localFormula.Path)
+	oldVersion  string
+	newVersion  string
+	sourceURL   string // woher das Upstream .rb geladen wurde (für die PR Beschreibung)
+}
+
+// commitResult beschreibt den erzeugten Commit.
+type commitResult struct {
+	branch     string
+	hash       plumbing.Hash
+	baseBranch string // Branch, auf den der Mirror danach wieder zeigt
+}
+
+// commitUpdates committet die Files aus bumped auf einen neuen Branch im Repo repoPath.
+// Existiert der Branch schon (z.B. zweiter Lauf am selben Tag), gibt es einen Fehler;
+// bestehende Branches werden nie überschrieben.
+func commitUpdates(repoPath string, bumped []bumpedFormula, now time.Time) (commitResult, error) {
+	if len(bumped) == 0 {
+		return commitResult{}, fmt.Errorf("nothing to commit")
+	}
+
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return commitResult{}, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return commitResult{}, err
+	}
+
+	// 1) Aktuellen Branch merken (detached HEAD können wir nicht sauber wiederherstellen)
+	head, err := repo.Head()
+	if err != nil {
+		return commitResult{}, err
+	}
+	if !head.Name().IsBranch() {
+		return commitResult{}, fmt.Errorf("mirror is not on a branch (detached HEAD at %s)", head.Hash())
+	}
+
+	// 2) Branch anlegen; Keep=true behält die geschriebenen Files im Worktree
+	branch := bumpBranchName(bumped, now)
+	ref := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(ref, false); err == nil {
+		return commitResult{}, fmt.Errorf("branch %s already exists in the mirror", branch)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: ref, Create: true, Keep: true}); err != nil {
+		return commitResult{}, fmt.Errorf("create branch %s: %w", branch, err)
+	}
+
+	// Ab hier bei Fehlern zurück auf den Ausgangsbranch (Files bleiben im Worktree)
+	restore := func() {
+		_ = wt.Checkout(&git.CheckoutOptions{Branch: head.Name(), Keep: true})
+		_ = repo.Storer.RemoveReference(ref)
+	}
+
+	// 3) Stagen: go-git erwartet Pfade relativ zum Repo-Root
+	for _, b := range bumped {
+		rel, err := filepath.Rel(repoPath, b.path)
+		if err != nil {
+			restore()
+			return commitResult{}, err
+		}
+		if _, err := wt.Add(filepath.ToSlash(rel)); err != nil {
+			restore()
+			return commitResult{}, fmt.Errorf("stage %s: %w", rel, err)
+		}
+	}
+
+	sig := commitSignature(now)
+	hash, err := wt.Commit(bumpCommitMessage(bumped), &git.CommitOptions{
+		Author:    sig,
+		Committer: sig,
+	})
+	if err != nil {
+		restore()
+		return commitResult{}, fmt.Errorf("commit: %w", err)
+	}
+
+	// 4) Zurück auf main/master; die Änderungen leben jetzt nur noch im neuen Branch
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: head.Name(), Force: true}); err != nil {
+		return commitResult{}, fmt.Errorf("switch back to %s: %w", head.Name().Short(), err)
+	}
+
+	return commitResult{branch: branch, hash: hash, baseBranch: head.Name().Short()}, nil
+}
+
+// bumpBranchName liefert den Branchnamen für den Commit:
+// - eine Formula: audit/bump-gov-abseil-20260107.0
+// - mehrere:      audit/bump-3-formulae-20261016
+func bumpBranchName(bumped []bumpedFormula, now time.Time) string {
+	if len(bumped) == 1 {
+		return "audit/bump-" + sanitizeRefPart(bumped[0].privateName+"-"+bumped[0].newVersion)
+	}
+	return fmt.Sprintf("audit/bump-%d-formulae-%s", len(bumped), now.UTC().Format("20060102"))
+}
+
+// sanitizeRefPart ersetzt Zeichen, die in Git Ref-Namen nicht erlaubt sind
+// (Leerzeichen, ~^:?*[\, "..", "@{") durch "-".
+func sanitizeRefPart(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune("~^:?*[\\", r) {
+			b.WriteByte('-')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	out := strings.ReplaceAll(b.String(), "..", ".")
+	out = strings.ReplaceAll(out, "@{", "-")
+	return strings.TrimSuffix(strings.TrimSuffix(out, ".lock"), ".")
+}
+
+// bumpCommitMessage baut die Commit Message im Homebrew-Stil:
+//
+//	gov-abseil 20260107.0
+//
+//	- gov-abseil: 20250814.1 -> 20260107.0
+//
+// Bei mehreren Formulae lautet die erste Zeile "Bump N formulae" (siehe bumpTitle).
+func bumpCommitMessage(bumped []bumpedFormula) string {
+	var b strings.Builder
+	b.WriteString(bumpTitle(bumped) + "\n\n")
+	for _, f := range bumped {
+		fmt.Fprintf(&b, "- %s: %s -> %s\n", f.privateName, f.oldVersion, f.newVersion)
+	}
+	return b.String()
+}
+
+// bumpTitle ist die erste Zeile der Commit Message (und der PR Titel).
+func bumpTitle(bumped []bumpedFormula) string {
+	if len(bumped) == 1 {
+		return bumped[0].privateName + " " + bumped[0].newVersion
+	}
+	return fmt.Sprintf("Bump %d formulae", len(bumped))
+}
+
+// commitSignature liest die Identität aus dem Environment (wie git selbst):
+// - GIT_AUTHOR_NAME / GIT_AUTHOR_EMAIL
+// - Fallback: "tap-version-audit" <tap-version-audit@localhost>
+func commitSignature(now time.Time) *object.Signature {
+	name := os.Getenv("GIT_AUTHOR_NAME")
+	if name == "" {
+		name = "tap-version-audit"
+	}
+	email := os.Getenv("GIT_AUTHOR_EMAIL")
+	if email == "" {
+		email = "tap-version-audit@localhost"
+	}
+	return &object.Signature{Name: name, Email: email, When: now}
+}
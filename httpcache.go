@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---- HTTP Cache für Upstream Lookups (.cache/http) ----
+//
+// httpCache ist ein http.RoundTripper vor dem normalen Transport. Er cached GET Antworten
+// (200 und 404) pro URL auf Platte und spart so bei Reruns den Download von formula.json,
+// der Formula-JSONs und der raw .rb Files:
+// 1) Eintrag jünger als ttl          -> direkt aus dem Cache, kein Request (Default ttl 0: nie)
+// 2) älter, mit ETag/Last-Modified   -> conditional Request; 304 -> Cache (und ttl neu)
+// 3) sonst / Cache Miss              -> normaler Request, Antwort wird gespeichert
+//    (ausser mit Cache-Control: no-store)
+// Mit --offline gibt es nur 1) ohne ttl; ein Miss ist ein Fehler (errNotCached).
+// Source-Archive (--verify-sha256) und Provider APIs laufen nicht über den Cache.
+
+// defaultCacheTTL: innerhalb dieser Zeit wird ohne Request aus dem Cache geantwortet.
+// 0 = jeder Run revalidiert (ein 304 kostet kaum etwas, liefert aber nie veraltete Versionen).
+const defaultCacheTTL = 0
+
+// errNotCached: --offline und die URL liegt nicht im Cache.
+var errNotCached = errors.New("not in the HTTP cache (run once without --offline)")
+
+// httpCache speichert pro URL <dir>/<sha256>.json (Metadaten) und <dir>/<sha256>.body.
+type httpCache struct {
+	dir     string
+	ttl     time.Duration
+	offline bool
+	next    http.RoundTripper // nil = http.DefaultTransport
+}
+
+// cacheEntry sind die Metadaten einer gespeicherten Antwort.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Status       int       `json:"status"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Stored       time.Time `json:"stored"` // letzter Download oder letzte 304 Bestätigung
+}
+
+// RoundTrip implementiert http.RoundTripper.
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := c.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// Nur einfache GETs cachen (keine Range Requests, keine eigenen Conditional Header)
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		if c.offline {
+			return nil, fmt.Errorf("offline: %s %s: %w", req.Method, req.URL.Redacted(), errNotCached)
+		}
+		return next.RoundTrip(req)
+	}
+
+	key := c.key(req.URL.String())
+	entry, ok := c.load(key)
+
+	// 1) offline bzw. frisch: direkt aus dem Cache
+	if c.offline {
+		if !ok {
+			return nil, fmt.Errorf("offline: %s: %w", req.URL.Redacted(), errNotCached)
+		}
+		return c.response(req, key, entry)
+	}
+	if ok && time.Since(entry.Stored) < c.ttl {
+		return c.response(req, key, entry)
+	}
+
+	// 2) abgelaufen: wenn möglich conditional Request
+	out := req
+	if ok && (entry.ETag != "" || entry.LastModified != "") {
+		out = req.Clone(req.Context())
+		if entry.ETag != "" {
+			out.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			out.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && ok {
+		_ = resp.Body.Close()
+		entry.Stored = time.Now()
+		if err := c.saveMeta(key, entry); err != nil {
+			return nil, err
+		}
+		return c.response(req, key, entry)
+	}
+
+	// 3) neue Antwort speichern (nur 200/404 ohne no-store; alles andere geht unverändert durch)
+	if (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound) || noStore(resp.Header) {
+		return resp, nil
+	}
+	entry = cacheEntry{
+		URL:          req.URL.String(),
+		Status:       resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Stored:       time.Now(),
+	}
+	err = c.saveBody(key, resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.saveMeta(key, entry); err != nil {
+		return nil, err
+	}
+	return c.response(req, key, entry)
+}
+
+// noStore: Cache-Control verbietet das Speichern der Antwort.
+func noStore(h http.Header) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), "no-store") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key ist der Dateiname (ohne Endung) für eine URL.
+func (c *httpCache) key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// load liest die Metadaten; fehlt die Datei oder der Body, ist das ein Miss.
+func (c *httpCache) load(key string) (cacheEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil {
+		return cacheEntry{}, false
+	}
+	if ok, _ := pathExists(filepath.Join(c.dir, key+".body")); !ok {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// saveBody schreibt den Body über eine temporäre Datei (parallele Worker sehen nie halbe Files).
+func (c *httpCache) saveBody(key string, body io.Reader) error {
+	return c.writeAtomic(key+".body", func(w io.Writer) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+// saveMeta schreibt die Metadaten (nach dem Body, damit load nie Meta ohne Body findet).
+func (c *httpCache) saveMeta(key string, e cacheEntry) error {
+	return c.writeAtomic(key+".json", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(e)
+	})
+}
+
+// writeAtomic schreibt name in c.dir via temp + rename.
+func (c *httpCache) writeAtomic(name string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("http cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("http cache: %w", err)
+	}
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("http cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("http cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("http cache: %w", err)
+	}
+	return nil
+}
+
+// response baut eine http.Response aus einem Cache-Eintrag; der Body wird direkt aus der Datei gelesen.
+func (c *httpCache) response(req *http.Request, key string, e cacheEntry) (*http.Response, error) {
+	f, err := os.Open(filepath.Join(c.dir, key+".body"))
+	if err != nil {
+		return nil, fmt.Errorf("http cache: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("http cache: %w", err)
+	}
+
+	h := http.Header{}
+	if e.ETag != "" {
+		h.Set("ETag", e.ETag)
+	}
+	if e.LastModified != "" {
+		h.Set("Last-Modified", e.LastModified)
+	}
+	if e.ContentType != "" {
+		h.Set("Content-Type", e.ContentType)
+	}
+	h.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          f,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHTTPCacheRoundTrip spielt pro Fall zwei Requests auf dieselbe URL durch:
+// der erste füllt (oder füllt nicht) den Cache, der zweite zeigt, woher die Antwort kommt.
+func TestHTTPCacheRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       map[string]string // Antwort-Header des Servers
+		status       int
+		ttl          time.Duration
+		offline      bool // zweiter Request mit --offline
+		wantRequests int32
+		wantCond     bool // zweiter Request kommt conditional (If-None-Match)
+		wantBody     string
+		wantErr      error
+	}{
+		{
+			name:         "ttl 0 revalidates, 304 serves the cached body",
+			header:       map[string]string{"ETag": `"v1"`},
+			status:       http.StatusOK,
+			wantRequests: 2,
+			wantCond:     true,
+			wantBody:     "body-1",
+		},
+		{
+			name:         "fresh entry answers without a request",
+			header:       map[string]string{"ETag": `"v1"`},
+			status:       http.StatusOK,
+			ttl:          time.Hour,
+			wantRequests: 1,
+			wantBody:     "body-1",
+		},
+		{
+			name:         "404 is cached too",
+			status:       http.StatusNotFound,
+			offline:      true,
+			wantRequests: 1,
+			wantBody:     "body-1",
+		},
+		{
+			name:         "offline hit ignores the ttl",
+			header:       map[string]string{"ETag": `"v1"`},
+			status:       http.StatusOK,
+			offline:      true,
+			wantRequests: 1,
+			wantBody:     "body-1",
+		},
+		{
+			name:         "no-store is not cached",
+			header:       map[string]string{"Cache-Control": "private, no-store"},
+			status:       http.StatusOK,
+			offline:      true,
+			wantRequests: 1,
+			wantErr:      errNotCached,
+		},
+		{
+			name:         "5xx is not cached",
+			status:       http.StatusBadGateway,
+			offline:      true,
+			wantRequests: 1,
+			wantErr:      errNotCached,
+		},
+		{
+			name:         "without validators the second request is a full GET",
+			status:       http.StatusOK,
+			wantRequests: 2,
+			wantBody:     "body-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			var cond atomic.Bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := requests.Add(1)
+				if etag := tt.header["ETag"]; etag != "" && r.Header.Get("If-None-Match") == etag {
+					cond.Store(true)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, "body-%d", n)
+			}))
+			defer srv.Close()
+
+			c := &httpCache{dir: t.TempDir(), ttl: tt.ttl}
+			client := &http.Client{Transport: c}
+
+			resp, err := client.Get(srv.URL + "/formula/foo.json")
+			if err != nil {
+				t.Fatalf("first GET: %v", err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			c.offline = tt.offline
+			resp, err = client.Get(srv.URL + "/formula/foo.json")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("second GET error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("second GET: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if cond.Load() != tt.wantCond {
+				t.Errorf("conditional request = %v, want %v", cond.Load(), tt.wantCond)
+			}
+		})
+	}
+}
+
+// TestHTTPCacheOfflineMiss: offline geht kein Request raus, auch nicht für nicht-cachebare Requests.
+func TestHTTPCacheOfflineMiss(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &httpCache{dir: t.TempDir(), offline: true}}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req, err := http.NewRequest(method, srv.URL+"/api/formula.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Do(req); !errors.Is(err, errNotCached) {
+			t.Errorf("%s error = %v, want errNotCached", method, err)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("offline sent %d requests", n)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---- Retry, Backoff und Rate Limits für Upstream Requests ----
+//
+// retryTransport sitzt unter dem HTTP Cache (httpcache.go), Cache Hits kosten also nie einen Retry:
+// 1) pro Host höchstens perHost gleichzeitige Requests (formulae.brew.sh, raw.githubusercontent.com, ...)
+// 2) jeder Versuch hat ein eigenes Timeout (attemptTimeout), Retries verlängern das Gesamtbudget
+// 3) Netzwerkfehler, 429, 5xx und 403 mit X-RateLimit-Remaining: 0 werden wiederholt (nur GET/HEAD)
+// 4) Wartezeit: Retry-After bzw. X-RateLimit-Reset, sonst exponentielles Backoff mit Full Jitter
+// 5) meldet ein Host "Remaining: 0", warten auch alle anderen Requests an ihn bis zum Reset
+// Verlangt der Host länger als maxWait, geben wir sofort mit der letzten Antwort auf.
+
+const (
+	defaultRetries    = 3
+	defaultPerHost    = 4
+	retryBaseDelay    = 500 * time.Millisecond
+	retryMaxDelay     = 30 * time.Second
+	retryMaxWait      = 2 * time.Minute // längste Wartezeit aus Retry-After / Rate-Limit Reset
+	retryMaxAfterBody = 64 << 10        // so viel Body wird vor einem Retry noch gelesen (Connection Reuse)
+)
+
+// retryTransport implementiert http.RoundTripper mit Retries und Host-Limits.
+// Mehrere Clients (normal, Index) teilen sich über hosts dieselben Limits.
+type retryTransport struct {
+	next           http.RoundTripper // nil = http.DefaultTransport
+	maxRetries     int
+	attemptTimeout time.Duration
+	hosts          *hostLimits
+}
+
+// hostLimits ist der gemeinsame Zustand pro Host.
+type hostLimits struct {
+	perHost int
+
+	mu      sync.Mutex
+	slots   map[string]chan struct{} // Semaphore pro Host
+	blocked map[string]time.Time     // Host -> Rate-Limit Reset
+}
+
+// newRetryTransport baut den Transport; perHost < 1 heisst ohne Host-Limit.
+func newRetryTransport(maxRetries, perHost int) *retryTransport {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &retryTransport{
+		maxRetries: maxRetries,
+		hosts: &hostLimits{
+			perHost: perHost,
+			slots:   map[string]chan struct{}{},
+			blocked: map[string]time.Time{},
+		},
+	}
+}
+
+// withTimeout liefert denselben Transport (gleiche Host-Limits) mit anderem Timeout pro Versuch.
+func (t *retryTransport) withTimeout(d time.Duration) *retryTransport {
+	c := *t
+	c.attemptTimeout = d
+	return &c
+}
+
+// newUpstreamClient baut den Client für Upstream Requests:
+// Cache (optional, nil = ohne) -> Retries/Host-Limits -> Netzwerk.
+// timeout gilt pro Versuch; ein Client-Timeout gibt es nicht, sonst würden Retries abgeschnitten.
+func newUpstreamClient(timeout time.Duration, cache *httpCache, retry *retryTransport) *http.Client {
+	var rt http.RoundTripper = retry.withTimeout(timeout)
+	if cache != nil {
+		c := *cache
+		c.next = rt
+		rt = &c
+	}
+	return &http.Client{Transport: rt}
+}
+
+// retryCountKey: Context Key für den Retry-Zähler eines Eintrags (siehe withRetryCounter).
+type retryCountKey struct{}
+
+// withRetryCounter liefert eine Kopie von client, deren Requests ihre Retries in n zählen.
+// So landen die Retries im Report beim richtigen privaten Eintrag.
+func withRetryCounter(client *http.Client, n *atomic.Int64) *http.Client {
+	c := *client
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return next.RoundTrip(req.WithContext(context.WithValue(req.Context(), retryCountKey{}, n)))
+	})
+	return &c
+}
+
+// roundTripFunc macht aus einer Funktion einen http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// RoundTrip implementiert http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// POST & Co. (z.B. Pull Requests) nie wiederholen, sonst gibt es sie doppelt
+	retries := t.maxRetries
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+	counter, _ := req.Context().Value(retryCountKey{}).(*atomic.Int64)
+	host := req.URL.Host
+
+	for attempt := 0; ; attempt++ {
+		// Host meldete "Remaining: 0": bis zum Reset warten (oder aufgeben, wenn das zu lange dauert)
+		if until := t.hosts.blockedUntil(host); !until.IsZero() {
+			wait := time.Until(until)
+			if wait > retryMaxWait {
+				return nil, fmt.Errorf("rate limited by %s until %s", host, until.Format(time.RFC3339))
+			}
+			if err := sleepCtx(req.Context(), wait); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.attempt(req, host)
+		wait, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry || attempt >= retries {
+			return resp, err
+		}
+		if wait > retryMaxWait {
+			// Host will, dass wir sehr lange warten: die Antwort (429/403) ist das Ergebnis
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.CopyN(io.Discard, resp.Body, retryMaxAfterBody)
+			_ = resp.Body.Close()
+		}
+		if counter != nil {
+			counter.Add(1)
+		}
+		if err := sleepCtx(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt schickt einen Versuch mit eigenem Timeout; Host-Slot und Timeout
+// bleiben bis zum Schliessen des Bodys belegt (Lesen gehört zum Request).
+func (t *retryTransport) attempt(req *http.Request, host string) (*http.Response, error) {
+	release, err := t.hosts.acquire(req.Context(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.attemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.attemptTimeout)
+	}
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	t.hosts.noteRateLimit(host, resp)
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { cancel(); release() }}
+	return resp, nil
+}
+
+// retryDelay entscheidet, ob ein Versuch wiederholt wird und wie lange vorher gewartet wird.
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		// Abbruch von aussen (Ctrl-C, Gesamt-Timeout) ist kein transienter Fehler
+		if req.Context().Err() != nil {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden && rateLimitRemaining(resp.Header) == "0":
+		if d, ok := retryAfter(resp.Header); ok {
+			return d, true
+		}
+		if reset, ok := rateLimitReset(resp.Header); ok {
+			return time.Until(reset), true
+		}
+		return backoff(attempt), true
+	case resp.StatusCode == http.StatusInternalServerError, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		if d, ok := retryAfter(resp.Header); ok {
+			return d, true
+		}
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff: exponentiell (base * 2^attempt, max retryMaxDelay) mit Full Jitter,
+// damit parallele Worker nicht im Gleichschritt wiederkommen.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(d))) + time.Millisecond
+}
+
+// retryAfter liest Retry-After (Sekunden oder HTTP-Datum).
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitRemaining / rateLimitReset lesen die Rate-Limit Header von GitHub (X-RateLimit-*)
+// bzw. der IETF Draft / GitLab Variante (RateLimit-*). Reset ist ein Unix Timestamp.
+func rateLimitRemaining(h http.Header) string {
+	if v := h.Get("X-RateLimit-Remaining"); v != "" {
+		return v
+	}
+	return h.Get("RateLimit-Remaining")
+}
+
+func rateLimitReset(h http.Header) (time.Time, bool) {
+	v := h.Get("X-RateLimit-Reset")
+	if v == "" {
+		v = h.Get("RateLimit-Reset")
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// noteRateLimit merkt sich "Remaining: 0" eines Hosts, damit andere Worker nicht ins Limit laufen.
+func (t *hostLimits) noteRateLimit(host string, resp *http.Response) {
+	if rateLimitRemaining(resp.Header) != "0" {
+		return
+	}
+	reset, ok := rateLimitReset(resp.Header)
+	if !ok || !reset.After(time.Now()) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reset.After(t.blocked[host]) {
+		t.blocked[host] = reset
+	}
+}
+
+// blockedUntil liefert den Reset-Zeitpunkt eines Hosts; Zero = nicht blockiert.
+func (t *hostLimits) blockedUntil(host string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until := t.blocked[host]
+	if !until.After(time.Now()) {
+		delete(t.blocked, host)
+		return time.Time{}
+	}
+	return until
+}
+
+// acquire belegt einen Slot für host (wartet, solange alle belegt sind).
+func (t *hostLimits) acquire(ctx context.Context, host string) (func(), error) {
+	if t.perHost < 1 {
+		return func() {}, nil
+	}
+	t.mu.Lock()
+	sem, ok := t.slots[host]
+	if !ok {
+		sem = make(chan struct{}, t.perHost)
+		t.slots[host] = sem
+	}
+	t.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-sem }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseBody gibt Slot und Timeout frei, sobald der Body geschlossen wird.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// sleepCtx wartet d, bricht aber ab, sobald ctx fertig ist.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// near: Wartezeiten aus Datum/Reset hängen an time.Now, darum mit etwas Toleranz vergleichen.
+func near(got, want time.Duration) bool {
+	d := got - want
+	return d > -2*time.Second && d < 2*time.Second
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "missing"},
+		{name: "seconds", value: "7", want: 7 * time.Second, wantOK: true},
+		{name: "zero seconds", value: "0", want: 0, wantOK: true},
+		{name: "negative", value: "-1"},
+		{name: "http date", value: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), want: 30 * time.Second, wantOK: true},
+		{name: "date in the past", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0, wantOK: true},
+		{name: "garbage", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfter(h)
+			if ok != tt.wantOK || !near(got, tt.want) {
+				t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(20*time.Second).Unix(), 10)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		status    int
+		header    map[string]string
+		err       error
+		ctx       context.Context
+		want      time.Duration // -1 = Backoff (zufällig, nur Obergrenze prüfen)
+		wantRetry bool
+	}{
+		{name: "200", status: http.StatusOK},
+		{name: "404", status: http.StatusNotFound},
+		{name: "429 with Retry-After seconds", status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "3"}, want: 3 * time.Second, wantRetry: true},
+		{name: "429 without headers", status: http.StatusTooManyRequests, want: -1, wantRetry: true},
+		{name: "403 rate limit reset", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, want: 20 * time.Second, wantRetry: true},
+		{name: "403 RateLimit draft headers", status: http.StatusForbidden, header: map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": reset}, want: 20 * time.Second, wantRetry: true},
+		{name: "403 Retry-After wins over reset", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset, "Retry-After": "1"}, want: time.Second, wantRetry: true},
+		{name: "403 without rate limit", status: http.StatusForbidden},
+		{name: "503 with Retry-After date", status: http.StatusServiceUnavailable, header: map[string]string{"Retry-After": time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)}, want: 10 * time.Second, wantRetry: true},
+		{name: "502 backoff", status: http.StatusBadGateway, want: -1, wantRetry: true},
+		{name: "501 is final", status: http.StatusNotImplemented},
+		{name: "network error", err: errors.New("connection reset"), want: -1, wantRetry: true},
+		{name: "canceled run", err: context.Canceled, ctx: canceled},
+	}
+
+	rt := newRetryTransport(3, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://formulae.brew.sh/api/formula.json", nil)
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status, Header: http.Header{}}
+				for k, v := range tt.header {
+					resp.Header.Set(k, v)
+				}
+			}
+			got, retry := rt.retryDelay(req, resp, tt.err, 0)
+			if retry != tt.wantRetry {
+				t.Fatalf("retryDelay() retry = %v, want %v", retry, tt.wantRetry)
+			}
+			switch {
+			case !retry:
+			case tt.want == -1:
+				if got <= 0 || got > retryBaseDelay+time.Millisecond {
+					t.Errorf("retryDelay() = %v, want backoff in (0, %v]", got, retryBaseDelay)
+				}
+			case !near(got, tt.want):
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, retryBaseDelay},
+		{1, 2 * retryBaseDelay},
+		{3, 8 * retryBaseDelay},
+		{10, retryMaxDelay},
+		{70, retryMaxDelay}, // Shift-Überlauf
+	}
+	for _, tt := range tests {
+		for range 200 {
+			if d := backoff(tt.attempt); d <= 0 || d > tt.max+time.Millisecond {
+				t.Fatalf("backoff(%d) = %v, want (0, %v]", tt.attempt, d, tt.max)
+			}
+		}
+	}
+}
+
+// TestRetryTransport: Wiederholung bis zum Erfolg, keine Retries für POST, Aufgabe bei zu langem Retry-After.
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		responses    []int  // Status pro Request, der letzte wiederholt sich
+		retryAfter   string // Retry-After bei Fehler-Status
+		wantStatus   int
+		wantRequests int32
+		wantRetries  int64
+	}{
+		{name: "503 then 200", method: http.MethodGet, responses: []int{503, 503, 200}, retryAfter: "0", wantStatus: 200, wantRequests: 3, wantRetries: 2},
+		{name: "gives up after max retries", method: http.MethodGet, responses: []int{502}, retryAfter: "0", wantStatus: 502, wantRequests: 4, wantRetries: 3},
+		{name: "POST is never retried", method: http.MethodPost, responses: []int{503, 200}, retryAfter: "0", wantStatus: 503, wantRequests: 1},
+		{name: "Retry-After beyond max wait", method: http.MethodGet, responses: []int{429, 200}, retryAfter: "3600", wantStatus: 429, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				status := tt.responses[min(n, len(tt.responses))-1]
+				if status != http.StatusOK {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			var retries atomic.Int64
+			client := withRetryCounter(&http.Client{Transport: newRetryTransport(3, 2)}, &retries)
+			req, _ := http.NewRequest(tt.method, srv.URL+"/api/formula/foo.json", nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if got := retries.Load(); got != tt.wantRetries {
+				t.Errorf("counted retries = %d, want %d", got, tt.wantRetries)
+			}
+		})
+	}
+}
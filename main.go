@@ -1,30 +1,82 @@
 package main
 
 import (
-	"flag"     // CLI Flags wie --update und --apply
-	"fmt"      // Ausgabe im Terminal
-	"net/http" // HTTP Client für Upstream API Requests
-	"os"       // Env Variablen, Exit Codes
-	"sort"     // Sortieren der Ergebnislisten
-	"time"     // Timeout für HTTP
+	"context"       // Abbruch per Ctrl-C / --timeout (runctx.go)
+	"flag"          // CLI Flags wie --update und --apply
+	"fmt"           // Ausgabe im Terminal
+	"net/http"      // HTTP Client für Upstream API Requests
+	"os"            // Env Variablen, Exit Codes
+	"path/filepath" // Pfad des HTTP Caches (.cache/http)
+	"sort"          // Sortieren der Ergebnislisten
+	"sync"          // Mutex/WaitGroup für den Worker Pool in compareAll
+	"sync/atomic"   // Retry-Zähler pro Eintrag (compareOne)
+	"time"          // Timeout für HTTP
 )
 
 // behindRow beschreibt einen Eintrag, der in deinem Private Tap "hinterher" ist
 // (d.h. upstream ist neuer als deine Version).
 type behindRow struct {
-	privateName string // z.B. "gov-abseil"
-	upstream    string // z.B. "abseil"
-	privateVer  string // Version aus deinem Tap (z.B. aus url/ version Zeile)
-	upstreamVer string // Version aus formulae.brew.sh (stable)
-	privatePath string // lokaler Pfad zur Datei im Mirror (.cache/private-tap/...)
+	tap         string        // Name des Taps (tapConfig.Name, "private" ohne --config)
+	privateName string        // z.B. "gov-abseil"
+	upstream    string        // z.B. "abseil"
+	privateVer  string        // Version aus deinem Tap (z.B. aus url/ version Zeile)
+	privateSrc  versionSource // woher privateVer stammt (version Stanza, git tag, url)
+	upstreamVer string        // Version aus formulae.brew.sh (stable)
+	privatePath string        // lokaler Pfad zur Datei im Mirror (.cache/private-tap/...)
+	kind        kind          // kindFormula oder kindCask (eigene Sektion im Report)
+	retries     int           // wiederholte Upstream Requests für diesen Eintrag (httpretry.go)
+}
+
+// notFoundRow beschreibt einen privaten Eintrag, den wir upstream nicht gefunden haben.
+type notFoundRow struct {
+	tap         string
+	privateName string // z.B. "gov-foo"
+	upstream    string // gesuchter upstream Name, z.B. "foo"
+	kind        kind
+	retries     int
+}
+
+// lookupError ist ein (nicht fataler) Fehler beim Upstream Lookup eines privaten Eintrags.
+// err bleibt als Original erhalten, damit Reports die Ursache strukturiert ausgeben können.
+type lookupError struct {
+	tap         string
+	privateName string
+	upstream    string
+	kind        kind
+	err         error
+	retries     int
+}
+
+// String liefert die klassische Textzeile, z.B. "gov-foo -> foo: upstream http status 500".
+// Casks bekommen ein Prefix, damit man sie in der Fehlerliste unterscheiden kann.
+func (e lookupError) String() string {
+	prefix := ""
+	if e.kind == kindCask {
+		prefix = "cask "
+	}
+	s := fmt.Sprintf("%s%s -> %s: %v", prefix, e.privateName, e.upstream, e.err)
+	if e.retries > 0 {
+		s += fmt.Sprintf(" (after %d retries)", e.retries)
+	}
+	return s
 }
 
 // report sammelt alle Resultate eines Runs, damit wir sie am Ende schön ausgeben können.
 type report struct {
-	privateCount int // (optional) Anzahl private formulae; du verwendest aktuell len(privateEntries)
+	generatedAt  time.Time // Zeitpunkt des Runs (für Reports)
+	privateCount int       // Anzahl private formulae (mit gefundener Version)
+	caskCount    int       // Anzahl private casks (mit gefundener Version)
 	behind       []behindRow
-	notFound     []string // private packages, die upstream nicht gefunden wurden (404)
-	errorsList   []string // HTTP / Parse / sonstige Fehler (nicht fatal, aber loggen)
+	upToDate     []behindRow      // aktuelle Einträge (gleiche Felder wie behind, z.B. für JUnit "passed")
+	notFound     []notFoundRow    // private packages, die upstream nicht gefunden wurden (404)
+	errorsList   []lookupError    // HTTP / Parse / sonstige Fehler (nicht fatal, aber loggen)
+	unparsed     []unparsedEntry  // Tap Files ohne extrahierbare Version (nicht verglichen)
+	lineFallback []unparsedEntry  // Version per Zeilen-Fallback gelesen, weil der Parser scheiterte (verglichen, aber unsicher)
+	ignored      []ignoredEntry   // per Config (ignore) ausgenommen (nicht verglichen)
+	taps         []string         // Tap Namen in Config-Reihenfolge (Gruppierung im Report)
+	duplicates   []duplicateEntry // gleicher upstream Eintrag in mehreren Taps
+	notChecked   []notFoundRow    // nicht verglichen, weil der Run abgebrochen wurde (Ctrl-C / --timeout)
+	incomplete   string           // Grund des Abbruchs, z.B. "interrupted (interrupt)"; "" = vollständig
 }
 
 func main() {
@@ -34,154 +86,642 @@ func main() {
 
 func run() int {
 	// 1) .env laden (optional)
-	//    Zweck: TAP_URL / BITBUCKET_USER / BITBUCKET_TOKEN etc. automatisch ins Environment laden
+	//    Zweck: TAP_URL / Tokens (TAP_TOKEN_<HOST>, BITBUCKET_*, GITHUB_TOKEN, ...) automatisch ins Environment laden
 	//    Wenn die Datei nicht existiert: kein Fehler (je nachdem wie loadDotEnv implementiert ist)
+	//    Fatale Fehler gehen über fail(): Meldung auf stderr plus Exit Code je Fehlerart (errors.go).
 	if err := loadDotEnv(".env"); err != nil {
-		panic(err)
+		return fail(context.Background(), errorf(errConfig, ".env: %w", err))
 	}
 
-	// Debug/Transparenz: Zeigt dir, ob TAP_URL überhaupt geladen wurde.
-	fmt.Println("TAP_URL:", os.Getenv("TAP_URL"))
-
 	// 2) CLI Flags definieren
-	// --update gov-abseil  -> ein einziges Package "updaten" (dry-run oder apply)
+	// --update gov-abseil  -> Packages "updaten" (dry-run oder apply); auch Liste/Glob: gov-a,gov-llvm*
+	// --update-all         -> alle Formulae, die behind sind
 	// --apply              -> wenn gesetzt: wirklich schreiben (sonst nur dry-run)
-	updateName := flag.String("update", "", "dry-run update one private formula (e.g. gov-abseil)")
-	apply := flag.Bool("apply", false, "write changes into the private tap mirror (no push!)")
+	updateName := flag.String("update", "", "dry-run update private formulae: name, comma list or glob (e.g. gov-abseil,gov-llvm*)")
+	configPath := flag.String("config", os.Getenv("TAP_AUDIT_CONFIG"), "JSON config: taps to audit in one run, name overrides, external taps, ignore list, prefix rules")
+	tapPath := flag.String("tap-path", "", "audit an existing local tap directory instead of mirroring TAP_URL (no git, --apply writes there)")
+	updateAll := flag.Bool("update-all", false, "dry-run update every formula that is behind upstream")
+	apply := flag.Bool("apply", false, "write changes into the tap (mirror or --tap-path; no push!)")
+	commit := flag.Bool("commit", false, "commit updated formulae to a new audit/bump-... branch in the mirror (implies --apply)")
+	push := flag.Bool("push", false, "push the audit branch to origin (implies --commit)")
+	openPR := flag.Bool("pr", false, "open a pull/merge request for the pushed branch (implies --push)")
+	providerName := flag.String("provider", os.Getenv("TAP_PROVIDER"), "git host of TAP_URL: bitbucket, github or gitlab (default: detected from the URL)")
+	apiURL := flag.String("api-url", firstEnv("TAP_API_URL", "BITBUCKET_API_URL"), "REST API base URL of the git host (default derived from TAP_URL)")
+	sshKey := flag.String("ssh-key", os.Getenv("TAP_SSH_KEY"), "private key for ssh TAP_URLs (passphrase via TAP_SSH_KEY_PASSPHRASE)")
+	sshAgent := flag.Bool("ssh-agent", false, "use ssh-agent for ssh TAP_URLs even if --ssh-key or ~/.ssh/id_* exist (default: agent only when no key file is usable)")
+	knownHosts := flag.String("known-hosts", os.Getenv("TAP_SSH_KNOWN_HOSTS"), "known_hosts file(s) for ssh host key verification (default: SSH_KNOWN_HOSTS or ~/.ssh/known_hosts)")
+	patchDir := flag.String("patch-dir", "", "write the update diff of each formula as <dir>/<name>.patch")
+	updateModeFlag := flag.String("update-mode", string(updateBump), "bump: rewrite url/sha256/version in place; replace: overwrite with the upstream file")
+	verifySHA := flag.Bool("verify-sha256", true, "with --apply: download the new source archive and verify its sha256 before writing (dry-runs only show the upstream sha256)")
+	maxArchiveMB := flag.Int64("max-archive-size", defaultMaxArchiveMB, "size limit in MB for source archives downloaded by --verify-sha256")
+	jobs := flag.Int("jobs", 8, "number of parallel upstream lookups")
+	timeout := flag.Duration("timeout", 0, "deadline for the whole run, e.g. 10m; when it expires the report is written as incomplete (0 = none)")
+	useCache := flag.Bool("http-cache", true, "cache upstream responses in .cache/http and revalidate them with ETag/Last-Modified")
+	cacheTTL := flag.Duration("cache-ttl", defaultCacheTTL, "answer from the HTTP cache without any request while an entry is younger than this (0 = revalidate every entry on each run)")
+	offline := flag.Bool("offline", false, "no network: upstream data only from the HTTP cache, taps only from existing mirrors")
+	retries := flag.Int("retries", defaultRetries, "retries per upstream request on network errors, 429 and 5xx (with backoff)")
+	perHost := flag.Int("max-per-host", defaultPerHost, "maximum parallel requests to one upstream host (0 = no limit)")
+	upstreamAPI := flag.String("upstream-api", firstEnv("TAP_UPSTREAM_API", "HOMEBREW_API_DOMAIN"), "base URL of the Homebrew JSON API (default "+defaultUpstreamAPI+")")
+	upstreamCoreRaw := flag.String("upstream-core-raw", os.Getenv("TAP_UPSTREAM_CORE_RAW"), "base URL for raw homebrew-core formula files (default "+defaultUpstreamCoreRaw+")")
+	upstreamGitHubRaw := flag.String("upstream-github-raw", os.Getenv("TAP_UPSTREAM_GITHUB_RAW"), "replaces "+defaultUpstreamGitHubRaw+" in external tap URLs")
+	useIndex := flag.Bool("index", true, "download the bulk formula.json index once instead of one request per formula")
+	format := flag.String("format", "text", "report format on stdout: text or json")
+	junitPath := flag.String("junit", "", "additionally write a JUnit XML report to this file")
+	reportFile := flag.String("report-file", "", "additionally write a Markdown (.md) or HTML (.html) summary to this file")
 	flag.Parse()
 
-	// 3) TAP_URL aus ENV holen (kommt aus .env oder aus deinem Shell Environment)
-	tapURL := os.Getenv("TAP_URL")
-	if tapURL == "" {
-		panic("TAP_URL environment variable not set")
-	}
+	// Run-Context: Ctrl-C / SIGTERM und --timeout brechen Clone/Pull, Upstream Requests und Updates ab.
+	// Der Report wird danach trotzdem geschrieben, als incomplete markiert (runctx.go).
+	ctx, stop := newRunContext(*timeout)
+	defer stop()
 
-	// 4) Private Tap Mirror sicherstellen:
-	//    - falls .cache/private-tap noch nicht existiert: clone (shallow)
-	//    - falls existiert: pull (main/master fallback)
-	//    Damit vergleichst du nicht gegen dein lokales /opt/homebrew/... Tap,
-	//    sondern gegen den aktuellen Stand von Bitbucket.
-	privateTapPath, err := ensureRepoMirror(".cache/private-tap", tapURL)
+	if *format != "text" && *format != "json" {
+		return fail(ctx, errorf(errConfig, "unknown --format %q (want text or json)", *format))
+	}
+	if *reportFile != "" {
+		if _, err := summaryFormatFor(*reportFile); err != nil {
+			return fail(ctx, newError(errConfig, err))
+		}
+	}
+	mode, err := parseUpdateMode(*updateModeFlag)
 	if err != nil {
-		panic(err)
+		return fail(ctx, newError(errConfig, err))
+	}
+	// --pr -> --push -> --commit -> --apply
+	if *openPR {
+		*push = true
+	}
+	if *push {
+		*commit = true
+	}
+	if *commit {
+		*apply = true
+	}
+	// --offline: Push/PR brauchen den Git Host; der Cache ist die einzige Upstream Quelle
+	if *offline {
+		if *push {
+			return fail(ctx, errorf(errConfig, "--offline cannot be combined with --push/--pr"))
+		}
+		*useCache = true
 	}
 
-	// 5) Aus dem lokalen Mirror alle Formula Files scannen und Version + Pfad extrahieren
-	//    Ergebnis: map[name]localFormula, z.B. "gov-abseil" -> {Version:"...", Path:".../gov-abseil.rb"}
-	privateEntries, err := loadFormulaEntries(privateTapPath)
-	if err != nil {
-		panic(err)
+	// 3) Config laden (optional, config.go): Name-Mappings, externe Taps, ignore, prefix rules
+	//    werden mit den eingebauten Defaults zusammengeführt, bevor irgendein Name gemappt wird.
+	var cfg auditConfig
+	if *configPath != "" {
+		cfg, err = loadAuditConfig(*configPath)
+		if err != nil {
+			return fail(ctx, newError(errConfig, err))
+		}
+		endpoints.merge(cfg.Upstream)
+	}
+	rules := cfg.nameRules()
+	//    Upstream Basis-URLs: Flag / Env gehen vor der Config (endpoints.go)
+	endpoints.merge(upstreamEndpoints{API: *upstreamAPI, CoreRaw: *upstreamCoreRaw, GitHubRaw: *upstreamGitHubRaw})
+	if err := endpoints.validate("upstream endpoint "); err != nil {
+		return fail(ctx, newError(errConfig, err))
+	}
+	if *format == "text" && !endpoints.isDefault() {
+		fmt.Printf("Upstream: api %s, core raw %s, github raw %s\n", endpoints.API, endpoints.CoreRaw, endpoints.GitHubRaw)
+	}
+
+	//    Taps bestimmen:
+	//    a) taps in der Config: mehrere Taps (url und/oder path) (taps.go)
+	//    b) --tap-path: vorhandenes Verzeichnis (z.B. $(brew --repo)/Library/Taps/org/homebrew-gov)
+	//       direkt lesen; kein TAP_URL, kein Git, kein Netzwerk für den Tap. --apply schreibt dorthin.
+	//    c) sonst: ein Tap aus TAP_URL, gespiegelt nach .cache/private-tap
+	//    b) und c) sind ein Tap "private" mit Prefix "gov-" (wie bisher).
+	var tapCfgs []tapConfig
+	fromConfig := len(cfg.Taps) > 0
+	switch {
+	case fromConfig:
+		if *tapPath != "" {
+			return fail(ctx, errorf(errConfig, "--tap-path cannot be combined with taps from --config (add a path tap to the config instead)"))
+		}
+		tapCfgs = cfg.Taps
+	case *tapPath != "":
+		tapCfgs = []tapConfig{{Name: defaultTapName, Path: *tapPath, Prefix: defaultPrefix}}
+	default:
+		// Debug/Transparenz: Zeigt dir, ob TAP_URL überhaupt geladen wurde.
+		// Bei --format json bleibt stdout reines JSON, darum nur im Text-Mode.
+		if *format == "text" {
+			fmt.Println("TAP_URL:", os.Getenv("TAP_URL"))
+		}
+		// TAP_URL aus ENV holen (kommt aus .env oder aus deinem Shell Environment)
+		tapURL := os.Getenv("TAP_URL")
+		if tapURL == "" {
+			return fail(ctx, errorf(errConfig, "TAP_URL environment variable not set (or use --tap-path / --config)"))
+		}
+		tapCfgs = []tapConfig{{Name: defaultTapName, URL: tapURL, Prefix: defaultPrefix}}
+	}
+
+	// Commit/Push arbeiten mit Branch-Wechseln im Repo; in deinem Working Tree wollen wir das nicht
+	if *commit {
+		for _, tc := range tapCfgs {
+			if tc.Path != "" {
+				return fail(ctx, errorf(errConfig, "--commit/--push/--pr need the managed mirror; tap %s is a local path, commit the changes yourself", tc.Name))
+			}
+		}
+	}
+
+	// 4) + 5) Pro Tap: Git Host bestimmen, Mirror sicherstellen (clone/pull) und Formulae/Casks scannen.
+	//    Damit vergleichst du nicht gegen dein lokales /opt/homebrew/... Tap,
+	//    sondern gegen den aktuellen Stand auf dem Git Host.
+	//    Files ohne extrahierbare Version kommen separat zurück (unparsed) und landen im Report.
+	sshOpts := sshOptions{
+		keyFile:    *sshKey,
+		passphrase: os.Getenv("TAP_SSH_KEY_PASSPHRASE"),
+		useAgent:   *sshAgent,
+		knownHosts: *knownHosts,
+	}
+	var (
+		taps     []*loadedTap
+		hasCasks bool
+	)
+	for _, tc := range tapCfgs {
+		t, err := openTap(ctx, tc, tapOpenOptions{
+			fromConfig:   fromConfig,
+			providerName: *providerName,
+			ssh:          sshOpts,
+			verbose:      *format == "text",
+			offline:      *offline,
+		})
+		if err != nil {
+			// auch bei Abbruch während Clone/Pull: ohne Tap gibt es nichts zu vergleichen, also keinen Report
+			return fail(ctx, err)
+		}
+		t.applyIgnore(cfg.Ignore)
+		// Für --pr muss der Provider bekannt sein; lieber vor dem ganzen Vergleich abbrechen als erst nach dem Push.
+		if *openPR && t.remote.provider == nil {
+			return fail(ctx, errorf(errConfig, "--pr: cannot detect the git provider of tap %s; set --provider or \"provider\" in the config (bitbucket, github or gitlab)", tc.Name))
+		}
+		taps = append(taps, t)
+		hasCasks = hasCasks || len(t.casks) > 0
 	}
 
 	// 6) HTTP Client erstellen (wiederverwenden, damit nicht pro Request ein neuer Client gebaut wird)
 	// Timeout verhindert "hängenbleiben", wenn upstream langsam ist.
-	client := &http.Client{Timeout: 15 * time.Second}
+	// Mit --http-cache laufen alle Upstream GETs über .cache/http (httpcache.go);
+	// darunter Retries mit Backoff und ein Limit paralleler Requests pro Host (httpretry.go).
+	retry := newRetryTransport(*retries, *perHost)
+	var cache *httpCache
+	if *useCache {
+		cache = &httpCache{dir: filepath.Join(".cache", "http"), ttl: *cacheTTL, offline: *offline}
+	}
+	if *offline {
+		// Archive werden nie gecached: --verify-sha256 scheitert offline mit errNotCached (nur mit --apply)
+		archiveClient.Transport = cache
+	}
+	client := newUpstreamClient(15*time.Second, cache, retry)
+
+	// 7) Optional: Bulk Index (formula.json) einmal laden.
+	//    Der Download ist gross, darum ein eigener Client mit längerem Timeout.
+	//    Wenn er fehlschlägt, fallen wir auf einen Request pro Formula zurück.
+	var idx *upstreamIndex
+	if *useIndex {
+		indexClient := newUpstreamClient(2*time.Minute, cache, retry)
+		idx, err = loadUpstreamIndex(ctx, indexClient)
+		if err != nil {
+			// nach Ctrl-C / --timeout keine Warnung, der Report sagt dann ohnehin "incomplete"
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "warning: upstream index unavailable, falling back to per-formula requests: %v\n", err)
+			}
+			idx = nil
+		}
+
+		//    cask.json nur laden, wenn der Tap überhaupt Casks hat
+		if idx != nil && hasCasks {
+			if err := loadCaskIndex(ctx, indexClient, idx); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "warning: cask index unavailable, falling back to per-cask requests: %v\n", err)
+			}
+		}
+	}
 
-	// 7) Vergleich machen: deine Version vs upstream stable Version
-	rep := compareAll(client, privateEntries)
+	// 8) Vergleich machen: deine Version vs upstream stable Version
+	//    --jobs bestimmt, wie viele Upstream Lookups parallel laufen
+	//    Bei mehreren Taps zusätzlich: welche upstream Einträge liegen in mehr als einem Tap?
+	//    Nach Ctrl-C / --timeout ist der Report unvollständig: was fehlt, steht unter notChecked.
+	rep := compareAll(ctx, client, idx, rules, taps, *jobs)
+	rep.incomplete = abortReason(ctx)
+	for _, t := range taps {
+		rep.unparsed = append(rep.unparsed, t.unparsed...)
+		rep.lineFallback = append(rep.lineFallback, t.lineFallback()...)
+		rep.ignored = append(rep.ignored, t.ignored...)
+	}
+	rep.duplicates = findDuplicates(taps, rules)
 
-	// 8) Report ausgeben (behind, notfound, errors)
-	printReport(privateEntries, rep)
+	// 9) Report ausgeben (behind, notfound, errors)
+	//    text: menschenlesbar, json: stabiles Schema für Dashboards/Bots (siehe report_json.go)
+	if *format == "json" {
+		if err := writeJSONReport(os.Stdout, rep); err != nil {
+			return fail(ctx, fmt.Errorf("write JSON report: %w", err))
+		}
+	} else {
+		printReport(rep)
+	}
 
-	// 9) Optional: Update-Mode für ein einzelnes Package (z.B. gov-abseil)
+	//    Optional zusätzlich: JUnit XML für CI Test-Dashboards
+	if *junitPath != "" {
+		if err := writeJUnitReport(*junitPath, rep); err != nil {
+			return fail(ctx, fmt.Errorf("write JUnit report: %w", err))
+		}
+	}
+
+	//    Optional zusätzlich: Markdown/HTML Zusammenfassung (PR Kommentare, Pages)
+	if *reportFile != "" {
+		if err := writeSummaryReport(*reportFile, rep); err != nil {
+			return fail(ctx, fmt.Errorf("write summary report: %w", err))
+		}
+	}
+
+	// 10) Optional: Update-Mode für einzelne oder alle behind Packages (z.B. gov-abseil, gov-llvm*)
 	//    Wichtig: in diesem Mode wollen wir NICHT mit Exit Code 2 rausgehen,
-	//    weil du es lokal testest und nur ein Update ansehen willst.
-	if *updateName != "" {
-		// Existiert dieses Package überhaupt in deinem privateEntries Map?
-		e, ok := privateEntries[*updateName]
-		if !ok {
-			panic("unknown private formula: " + *updateName)
+	//    weil du es lokal testest und nur die Updates ansehen willst.
+	if *updateName != "" || *updateAll {
+		// Bei --format json steht auf stdout nur der Report; Diffs, Summary, Commit/Push nach stderr
+		if *format == "json" {
+			updateOut = os.Stderr
+		}
+
+		// Ziele bestimmen; unbekannte Namen/Globs sind Fehler im Summary statt panic
+		targets, unknown := selectUpdateTargets(*updateName, *updateAll, taps, rep)
+
+		// Führt Dry-Run oder Apply pro Formula aus:
+		// - updateOne(..., apply=false) -> zeigt nur den Diff, schreibt nichts (ausser --patch-dir)
+		// - updateOne(..., apply=true)  -> schreibt ins Mirror File (commit nur mit --commit, nie push)
+		// --update-mode bestimmt, ob dein File umgeschrieben (bump) oder ersetzt (replace) wird.
+		// --verify-sha256 lädt mit --apply pro Formula das neue Archiv (max. --max-archive-size MB) und prüft den sha256.
+		opts := updateOptions{
+			apply:           *apply,
+			mode:            mode,
+			patchDir:        *patchDir,
+			commit:          *commit,
+			verifySHA256:    *verifySHA,
+			maxArchiveBytes: *maxArchiveMB << 20,
+			rules:           rules,
 		}
+		results := runUpdates(ctx, client, targets, opts)
 
-		// Führt Dry-Run oder Apply aus:
-		// - dryRunUpdateOne(..., apply=false) -> zeigt nur Preview, schreibt nichts
-		// - dryRunUpdateOne(..., apply=true)  -> schreibt ins Mirror File, aber macht kein commit/push
-		if err := updateOne(client, *updateName, e, *apply); err != nil {
-			panic(err)
+		// Exit Code: 7 wenn ein Update (oder ein Name) fehlschlug, 4 wenn Commit/Push/PR fehlschlug (errors.go)
+		code := exitOK
+		if printUpdateSummary(append(unknown, results...), *apply) {
+			code = exitUpdate
 		}
 
-		// Update-Mode = immer 0, damit dein Terminal nicht mit exit status 2 endet.
-		return 0
+		// --commit: alle tatsächlich geänderten Files eines Taps in einem Commit auf einen neuen Branch
+		// (auch wenn einzelne Updates fehlgeschlagen sind; die landen nicht im Commit).
+		// Bei mehreren Taps gibt es pro Tap einen Branch (und ggf. einen PR).
+		// Nach Ctrl-C / --timeout wird nichts mehr committed; geschriebene Files bleiben im Mirror.
+		switch {
+		case *commit && ctx.Err() != nil:
+			fmt.Fprintf(os.Stderr, "Not committing: %s. Updated files stay uncommitted in the mirror.\n", abortReason(ctx))
+		case *commit:
+			byTap := map[*loadedTap][]bumpedFormula{}
+			for _, r := range results {
+				if r.bumped != nil {
+					byTap[r.bumped.tap] = append(byTap[r.bumped.tap], *r.bumped)
+				}
+			}
+			if len(byTap) == 0 {
+				fmt.Fprintln(updateOut, "Nothing to commit: no formula file changed.")
+			}
+			for _, t := range taps {
+				bumped := byTap[t]
+				if len(bumped) == 0 {
+					continue
+				}
+				if ctx.Err() != nil {
+					fmt.Fprintf(os.Stderr, "error: tap %s not committed: %s\n", t.cfg.Name, abortReason(ctx))
+					continue
+				}
+				res, err := commitUpdates(t.dir, bumped, time.Now())
+				if err != nil {
+					code = fail(ctx, errorf(errGit, "commit failed for tap %s: %w", t.cfg.Name, err))
+					continue
+				}
+				fmt.Fprintf(updateOut, "Committed %d formula(e) to branch %s (%s); mirror is back on %s.\n",
+					len(bumped), res.branch, res.hash.String()[:7], res.baseBranch)
+				if !*push {
+					fmt.Fprintf(updateOut, "Next: cd %s && git show %s\n\n", t.dir, res.branch)
+					continue
+				}
+				apiBase := t.cfg.APIURL
+				if apiBase == "" {
+					apiBase = *apiURL
+				}
+				if err := publishBump(ctx, client, t.dir, res, bumped, *openPR, t.remote.provider, t.remote.auth, t.remote.cred, apiBase); err != nil {
+					code = fail(ctx, fmt.Errorf("tap %s: %w", t.cfg.Name, gitError(err)))
+				}
+			}
+		}
+
+		// Update-Mode = 0, solange alle Updates geklappt haben (kein exit status 2 wegen behind).
+		if ctx.Err() != nil {
+			return abortExitCode(ctx)
+		}
+		return code
 	}
 
-	// 10) CI Signal: Wenn irgendetwas hinterher ist, geben wir 2 zurück.
+	// 11) Abgebrochen: der Report ist unvollständig, "alles aktuell" (0) oder 2 wären gelogen.
+	if rep.incomplete != "" {
+		return abortExitCode(ctx)
+	}
+
+	// 12) Upstream Lookups fehlgeschlagen: der Report ist unzuverlässig, das geht vor "behind".
+	if len(rep.errorsList) > 0 {
+		fmt.Fprintf(os.Stderr, "upstream error: %d lookup(s) failed, see Errors in the report\n", len(rep.errorsList))
+		return exitUpstream
+	}
+
+	// 13) CI Signal: Wenn irgendetwas hinterher ist, geben wir 2 zurück.
 	//     Das ist hilfreich, wenn du es später in Pipelines laufen lässt.
 	if len(rep.behind) > 0 {
-		return 2
+		return exitBehind
 	}
 
-	// 11) Alles aktuell -> Exit 0
-	return 0
+	// 14) Alles aktuell -> Exit 0
+	return exitOK
 }
 
-func compareAll(client *http.Client, privateEntries map[string]localFormula) report {
+// compareJob ist eine Arbeitseinheit für den Worker Pool in compareAll.
+type compareJob struct {
+	name  string
+	entry localFormula
+}
+
+// compareAll vergleicht alle privaten Formulae und Casks aller Taps mit upstream.
+//
+// idx ist der optionale Bulk Index (formula.json / cask.json); nil heisst ein Request pro Eintrag.
+//
+// Die Upstream Lookups laufen parallel in einem Worker Pool mit `jobs` Workern
+// (jobs < 1 wird als 1 behandelt). Die Worker schreiben unter einem Mutex in
+// denselben report; die Reihenfolge stellt am Ende das Sortieren wieder her.
+//
+// Ist ctx beendet (Ctrl-C / --timeout), holen die Worker die restlichen Einträge nur noch
+// ab und tragen sie als notChecked ein; laufende Requests brechen über ctx ab.
+func compareAll(ctx context.Context, client *http.Client, idx *upstreamIndex, rules nameRules, taps []*loadedTap, jobs int) report {
 	// Wir bauen das report Objekt zusammen und liefern es zurück.
-	var rep report
+	rep := report{generatedAt: time.Now().UTC()}
+	tapOrder := map[string]int{}
+	for i, t := range taps {
+		rep.privateCount += len(t.formulae)
+		rep.caskCount += len(t.casks)
+		rep.taps = append(rep.taps, t.cfg.Name)
+		tapOrder[t.cfg.Name] = i
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
 
-	// Loop über alle privaten Formulae
-	for pName, e := range privateEntries {
-		// lokale Version (aus deinem Parser)
-		pVer := e.Version
+	var (
+		mu sync.Mutex     // schützt rep, weil mehrere Worker gleichzeitig appenden
+		wg sync.WaitGroup // wartet, bis alle Worker fertig sind
+	)
 
-		// privateName -> upstreamName (gov-foo@... -> foo / overrides etc.)
-		upName := toUpstreamName(pName)
+	// Arbeits-Queue: jeder private Eintrag wird genau einmal von einem Worker abgeholt
+	queue := make(chan compareJob)
 
-		// Upstream stable Version holen (über formulae.brew.sh API, plus fallback taps falls eingebaut)
-		upVer, ok, err := fetchUpstreamStable(client, upName)
-		if err != nil {
-			// Fehler bei HTTP/JSON/Parsing -> wir sammeln es, aber brechen nicht alles ab
-			rep.errorsList = append(rep.errorsList, fmt.Sprintf("%s -> %s: %v", pName, upName, err))
-			continue
-		}
-		if !ok {
-			// Upstream nicht gefunden (404) -> in notFound Liste aufnehmen
-			rep.notFound = append(rep.notFound, pName)
-			continue
-		}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				compareOne(ctx, client, idx, rules, j.name, j.entry, &rep, &mu)
+			}
+		}()
+	}
 
-		// Versionsvergleich (deine Version kleiner als upstream = behind)
-		if isBehind(pVer, upVer) {
-			rep.behind = append(rep.behind, behindRow{
-				privateName: pName,
-				upstream:    upName,
-				privateVer:  pVer,
-				upstreamVer: upVer,
-				privatePath: e.Path, // extrem wichtig fürs spätere Apply/Overwrite
-			})
+	// Loop über alle privaten Formulae und Casks aller Taps und an die Worker verteilen
+	for _, t := range taps {
+		for pName, e := range t.formulae {
+			queue <- compareJob{name: pName, entry: e}
+		}
+		for token, e := range t.casks {
+			queue <- compareJob{name: token, entry: e}
 		}
 	}
+	close(queue)
+	wg.Wait()
 
 	// Ergebnislisten sortieren, damit Output reproduzierbar ist
-	sort.Slice(rep.behind, func(i, j int) bool { return rep.behind[i].privateName < rep.behind[j].privateName })
-	sort.Strings(rep.notFound)
-	sort.Strings(rep.errorsList)
+	// (Taps in Config-Reihenfolge, darin Formulae vor Casks, innerhalb nach Name)
+	less := func(tapA, tapB string, kindA, kindB kind, nameA, nameB string) bool {
+		if tapA != tapB {
+			return tapOrder[tapA] < tapOrder[tapB]
+		}
+		if kindA != kindB {
+			return kindA < kindB
+		}
+		return nameA < nameB
+	}
+	sort.Slice(rep.behind, func(i, j int) bool {
+		a, b := rep.behind[i], rep.behind[j]
+		return less(a.tap, b.tap, a.kind, b.kind, a.privateName, b.privateName)
+	})
+	sort.Slice(rep.upToDate, func(i, j int) bool {
+		a, b := rep.upToDate[i], rep.upToDate[j]
+		return less(a.tap, b.tap, a.kind, b.kind, a.privateName, b.privateName)
+	})
+	sort.Slice(rep.notFound, func(i, j int) bool {
+		a, b := rep.notFound[i], rep.notFound[j]
+		return less(a.tap, b.tap, a.kind, b.kind, a.privateName, b.privateName)
+	})
+	sort.Slice(rep.notChecked, func(i, j int) bool {
+		a, b := rep.notChecked[i], rep.notChecked[j]
+		return less(a.tap, b.tap, a.kind, b.kind, a.privateName, b.privateName)
+	})
+	sort.Slice(rep.errorsList, func(i, j int) bool {
+		a, b := rep.errorsList[i], rep.errorsList[j]
+		if a.tap != b.tap {
+			return tapOrder[a.tap] < tapOrder[b.tap]
+		}
+		return a.String() < b.String()
+	})
 
 	return rep
 }
 
-func printReport(privateEntries map[string]localFormula, rep report) {
+// compareOne macht den Upstream Lookup für einen privaten Eintrag und trägt das
+// Resultat (behind / notFound / Fehler / notChecked) unter mu in rep ein.
+func compareOne(ctx context.Context, client *http.Client, idx *upstreamIndex, rules nameRules, pName string, e localFormula, rep *report, mu *sync.Mutex) {
+	// lokale Version (aus deinem Parser)
+	pVer := e.Version
+
+	// privateName -> upstreamName (gov-foo@... -> foo / overrides etc.; Prefix pro Tap)
+	upName := rules.toUpstreamName(pName, e.Prefix, e.Kind)
+
+	// Run abgebrochen: keinen Lookup mehr starten
+	if ctx.Err() != nil {
+		mu.Lock()
+		rep.notChecked = append(rep.notChecked, notFoundRow{tap: e.Tap, privateName: pName, upstream: upName, kind: e.Kind})
+		mu.Unlock()
+		return
+	}
+
+	// Upstream Version holen (Index oder formulae.brew.sh API, plus fallback taps falls eingebaut)
+	// Der HTTP Request läuft ausserhalb des Locks, nur das Eintragen ist geschützt.
+	// Retries (httpretry.go) werden pro Eintrag gezählt und landen im Report.
+	var (
+		upVer   string
+		ok      bool
+		err     error
+		retries atomic.Int64
+	)
+	lookupClient := withRetryCounter(client, &retries)
+	if e.Kind == kindCask {
+		upVer, ok, err = lookupUpstreamCask(ctx, lookupClient, idx, upName)
+	} else {
+		upVer, ok, err = lookupUpstreamStable(ctx, lookupClient, idx, rules, upName)
+	}
+	n := int(retries.Load())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		// Request durch den Abbruch beendet: kein Upstream-Fehler, nur nicht geprüft
+		rep.notChecked = append(rep.notChecked, notFoundRow{tap: e.Tap, privateName: pName, upstream: upName, kind: e.Kind, retries: n})
+		return
+	}
+	if err != nil {
+		// Fehler bei HTTP/JSON/Parsing -> wir sammeln es, aber brechen nicht alles ab
+		rep.errorsList = append(rep.errorsList, lookupError{tap: e.Tap, privateName: pName, upstream: upName, kind: e.Kind, err: err, retries: n})
+		return
+	}
+	if !ok {
+		// Upstream nicht gefunden (404) -> in notFound Liste aufnehmen
+		rep.notFound = append(rep.notFound, notFoundRow{tap: e.Tap, privateName: pName, upstream: upName, kind: e.Kind, retries: n})
+		return
+	}
+
+	row := behindRow{
+		tap:         e.Tap,
+		privateName: pName,
+		upstream:    upName,
+		privateVer:  pVer,
+		privateSrc:  e.VersionSource,
+		upstreamVer: upVer,
+		privatePath: e.Path, // extrem wichtig fürs spätere Apply/Overwrite
+		kind:        e.Kind,
+		retries:     n,
+	}
+
+	// Versionsvergleich (deine Version kleiner als upstream = behind)
+	if isBehind(pVer, upVer) {
+		rep.behind = append(rep.behind, row)
+	} else {
+		rep.upToDate = append(rep.upToDate, row)
+	}
+}
+
+// retryCount summiert die Retries aller verglichenen Einträge.
+func (rep report) retryCount() int {
+	n := 0
+	for _, r := range rep.behind {
+		n += r.retries
+	}
+	for _, r := range rep.upToDate {
+		n += r.retries
+	}
+	for _, nf := range rep.notFound {
+		n += nf.retries
+	}
+	for _, le := range rep.errorsList {
+		n += le.retries
+	}
+	for _, nc := range rep.notChecked {
+		n += nc.retries
+	}
+	return n
+}
+
+// printReport gibt den Text-Report aus. Bei mehreren Taps zuerst die Duplikate,
+// dann pro Tap ein eigener Block (gleiches Format wie im Single-Tap Mode).
+func printReport(rep report) {
+	// Abgebrochener Run: ganz oben, damit niemand einen Teil-Report für vollständig hält
+	if rep.incomplete != "" {
+		fmt.Printf("INCOMPLETE: %s; %d entries not checked\n\n", rep.incomplete, len(rep.notChecked))
+	}
+	if len(rep.taps) <= 1 {
+		printTapReport(rep)
+		return
+	}
+
+	fmt.Printf("Taps: %d\n", len(rep.taps))
+	fmt.Printf("Duplicates across taps: %d\n\n", len(rep.duplicates))
+	if len(rep.duplicates) > 0 {
+		fmt.Println("=== Duplicates across taps ===")
+		for _, d := range rep.duplicates {
+			fmt.Printf("- %s\n", d)
+		}
+		fmt.Println()
+	}
+
+	for _, name := range rep.taps {
+		fmt.Printf("##### Tap %s #####\n", name)
+		printTapReport(rep.forTap(name))
+	}
+}
+
+// printTapReport gibt Summary und Listen eines (gefilterten) Reports aus.
+func printTapReport(rep report) {
 	// Summary
-	fmt.Printf("Private Tap Formulae (found Version): %d\n", len(privateEntries))
+	fmt.Printf("Private Tap Formulae (found Version): %d\n", rep.privateCount)
+	if rep.caskCount > 0 {
+		fmt.Printf("Private Tap Casks (found Version): %d\n", rep.caskCount)
+	}
 	fmt.Printf("Behind upstream: %d\n", len(rep.behind))
 	fmt.Printf("Not found upstream: %d\n", len(rep.notFound))
+	fmt.Printf("Unparsed (no version found): %d\n", len(rep.unparsed))
+	if len(rep.lineFallback) > 0 {
+		fmt.Printf("Parsed by line fallback: %d\n", len(rep.lineFallback))
+	}
+	if len(rep.ignored) > 0 {
+		fmt.Printf("Ignored (config): %d\n", len(rep.ignored))
+	}
+	if len(rep.notChecked) > 0 {
+		fmt.Printf("Not checked (run aborted): %d\n", len(rep.notChecked))
+	}
+	if n := rep.retryCount(); n > 0 {
+		fmt.Printf("Retried upstream requests: %d\n", n)
+	}
 	fmt.Printf("HTTP/Parse Error: %d\n\n", len(rep.errorsList))
 
-	// Liste der veralteten Packages
-	if len(rep.behind) > 0 {
-		fmt.Printf("=== Behind Upstream (Please update) ===\n")
-		for _, r := range rep.behind {
-			// nur Anzeige: welches Package ist alt und welche Versionen
-			fmt.Printf(" - %s (upstream: %s): %s -> %s\n", r.privateName, r.upstream, r.privateVer, r.upstreamVer)
-		}
-		fmt.Println()
-	}
+	// Liste der veralteten Packages (Formulae und Casks in eigenen Sektionen)
+	printBehindSection("=== Behind Upstream (Please update) ===", rep.behind, kindFormula)
+	printBehindSection("=== Casks Behind Upstream (Please update) ===", rep.behind, kindCask)
 
 	// Liste von packages, die upstream nicht gefunden wurden
 	// (meist: anderer Tap, anderer Name, oder nur in cask)
 	if len(rep.notFound) > 0 {
 		fmt.Println("=== Not found Upstream (firts 15) ===")
 		for i := 0; i < 25 && i < len(rep.notFound); i++ {
-			fmt.Printf("- %s (searching upstream: %s)\n", rep.notFound[i], toUpstreamName(rep.notFound[i]))
+			nf := rep.notFound[i]
+			if nf.kind == kindCask {
+				fmt.Printf("- %s (cask, searching upstream: %s)\n", nf.privateName, nf.upstream)
+			} else {
+				fmt.Printf("- %s (searching upstream: %s)\n", nf.privateName, nf.upstream)
+			}
+		}
+		fmt.Println()
+	}
+
+	// Files, aus denen wir keine Version lesen konnten (vollständig, sind meist wenige)
+	if len(rep.unparsed) > 0 {
+		fmt.Println("=== Unparsed (no version found) ===")
+		for _, u := range rep.unparsed {
+			fmt.Printf("- %s (%s): %s [%s]\n", u.Name, u.Kind, u.Reason, u.Path)
+		}
+		fmt.Println()
+	}
+
+	// Files, an denen der Parser scheiterte: Version kommt aus dem Zeilen-Fallback und kann
+	// aus dem falschen Block stammen (head/resource) -> bitte prüfen
+	if len(rep.lineFallback) > 0 {
+		fmt.Println("=== Parsed by line fallback (please check) ===")
+		for _, u := range rep.lineFallback {
+			fmt.Printf("- %s: %s [%s]\n", u.Name, u.Reason, u.Path)
 		}
 		fmt.Println()
 	}
@@ -190,8 +730,28 @@ func printReport(privateEntries map[string]localFormula, rep report) {
 	if len(rep.errorsList) > 0 {
 		fmt.Println("=== Errors (first 10) ===")
 		for i := 0; i < 10 && i < len(rep.errorsList); i++ {
-			fmt.Printf("- %s\n", rep.errorsList[i])
+			fmt.Printf("- %s\n", rep.errorsList[i].String())
 		}
 		fmt.Println()
 	}
 }
+
+// printBehindSection gibt alle behind Einträge einer Art (Formula/Cask) unter title aus.
+// Gibt es keine, wird die Sektion komplett weggelassen.
+func printBehindSection(title string, rows []behindRow, k kind) {
+	printed := false
+	for _, r := range rows {
+		if r.kind != k {
+			continue
+		}
+		if !printed {
+			fmt.Println(title)
+			printed = true
+		}
+		// nur Anzeige: welches Package ist alt und welche Versionen
+		fmt.Printf(" - %s (upstream: %s): %s -> %s (private version from %s)\n", r.privateName, r.upstream, r.privateVer, r.upstreamVer, r.privateSrc)
+	}
+	if printed {
+		fmt.Println()
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// go-git ist eine reine Go-Implementation von Git (clone/pull ohne externes git binary)
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"             // RefSpecs für Push
+	"github.com/go-git/go-git/v5/plumbing"           // Branch/Reference-Namen, Low-Level Git-Objekte
+	"github.com/go-git/go-git/v5/plumbing/transport" // AuthMethod (HTTPS: credentials.go, SSH: ssh_auth.go)
+)
+
+// tapRemote bündelt alles, was wir über den Git Host des Taps wissen.
+type tapRemote struct {
+	provider gitProvider          // nil = unbekannter Host (Mirror geht, --pr nicht)
+	cred     *credential          // HTTPS Credentials bzw. API Token; nil = keine gefunden
+	auth     transport.AuthMethod // Git Auth (HTTPS BasicAuth oder SSH); nil = anonym
+}
+
+// connectTapRemote bestimmt Provider, Credentials und Git Auth für tapURL.
+// Fehler: unbrauchbare URL / --provider sind config, SSH Key / known_hosts sind auth (errors.go).
+func connectTapRemote(ctx context.Context, tapURL, providerName string, ssh sshOptions) (tapRemote, error) {
+	provider, err := detectProvider(tapURL, providerName)
+	if err != nil {
+		return tapRemote{}, newError(errConfig, err)
+	}
+	cred := resolveCredential(ctx, tapURL, provider, providerName != "")
+	auth, err := remoteAuth(tapURL, cred, ssh)
+	if err != nil {
+		return tapRemote{}, newError(errAuth, err)
+	}
+	return tapRemote{provider: provider, cred: cred, auth: auth}, nil
+}
+
+// ensureRepoMirror stellt sicher, dass du lokal unter `dst` immer ein aktuelles Checkout deines
+// Tap-Repos hast (Bitbucket, GitHub, GitLab oder ein anderer Git Host).
+//
+// Ablauf:
+// 1) Cache-Ordner anlegen (.cache)
+// 2) Prüfen ob dst existiert
+//   - wenn nein: clone (branch, bzw. main mit fallback master)
+//   - wenn ja: pull (branch, bzw. main mit fallback master)
+//
+// branch "" heisst main/master Fallback; sonst genau dieser Branch (tapConfig.Branch).
+// auth kommt aus remoteAuth (HTTPS BasicAuth oder SSH Key/Agent); nil = anonym.
+// ctx bricht Clone/Pull ab (Ctrl-C, --timeout); ein abgebrochener Clone räumt go-git selbst weg,
+// ein abgebrochener Pull lässt den Mirror stehen (kein Löschen + Neu-Clonen).
+//
+// Rückgabe:
+// - string: Pfad zum lokalen Mirror-Ordner (dst)
+// - error: falls etwas schiefgeht
+func ensureRepoMirror(ctx context.Context, dst, url, branch string, auth transport.AuthMethod) (string, error) {
+	// Cache-Ordner anlegen (falls nicht vorhanden; bei mehreren Taps .cache/taps)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+
+	// Prüfen ob der Zielordner (Mirror) schon existiert
+	exists, err := pathExists(dst)
+	if err != nil {
+		return "", err
+	}
+
+	// Wenn Mirror noch nicht existiert: Repository clonen
+	if !exists {
+		if err := cloneWithFallback(ctx, dst, url, auth, branch); err != nil {
+			return "", err
+		}
+		return dst, nil
+	}
+
+	// Wenn Mirror existiert: Repository aktualisieren (pull)
+	if err := pullWithFallback(ctx, dst, auth, branch); err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		_ = os.RemoveAll(dst)
+		if err2 := cloneWithFallback(ctx, dst, url, auth, branch); err2 != nil {
+			return "", err2
+		}
+	}
+
+	return dst, nil
+}
+
+// pathExists prüft, ob ein Pfad existiert.
+//
+// Rückgabe:
+// - true, nil  -> Pfad existiert
+// - false, nil -> Pfad existiert nicht
+// - false, err -> Fehler beim Stat (z.B. Permission)
+func pathExists(p string) (bool, error) {
+	_, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// cloneWithFallback versucht das Repo zuerst vom Branch "main" zu clonen.
+// Falls das fehlschlägt (z.B. weil es den Branch nicht gibt), versucht es "master".
+// Ist branch gesetzt, gibt es keinen Fallback.
+func cloneWithFallback(ctx context.Context, dst, url string, auth transport.AuthMethod, branch string) error {
+	if branch != "" {
+		return cloneBranch(ctx, dst, url, auth, branch)
+	}
+	// 1) main versuchen
+	err := cloneBranch(ctx, dst, url, auth, "main")
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	// 2) fallback: master versuchen
+	return cloneBranch(ctx, dst, url, auth, "master")
+}
+
+// cloneBranch clont ein Repo in den Ordner `dst`.
+//
+// Parameter:
+// - dst: Zielordner für Clone
+// - url: Repo URL (https://... .git, ssh://... oder git@host:org/repo.git)
+// - auth: Auth für private Repos (nil = anonym)
+// - branch: Branchname ("main" oder "master")
+//
+// Hinweise:
+// - SingleBranch: nur ein Branch, spart Zeit/Traffic
+// - Depth: 1 => shallow clone (nur aktuellster Stand), sehr schnell
+func cloneBranch(ctx context.Context, dst, url string, auth transport.AuthMethod, branch string) error {
+	_, err := git.PlainCloneContext(ctx, dst, false, &git.CloneOptions{
+		URL:           url,
+		Auth:          auth,
+		SingleBranch:  true,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		Depth:         1,
+	})
+	return err
+}
+
+// pullWithFallback öffnet das bestehende Repo in `dst` und macht ein Pull.
+//
+// Logik:
+// - zuerst Pull von "main"
+// - wenn das nicht klappt: Pull von "master"
+// - NoErrAlreadyUpToDate ist OK (heisst: nichts zu tun)
+// - branch gesetzt: nur dieser Branch, kein Fallback
+func pullWithFallback(ctx context.Context, dst string, auth transport.AuthMethod, branch string) error {
+	// Existierendes Repo öffnen
+	repo, err := git.PlainOpen(dst)
+	if err != nil {
+		return err
+	}
+
+	// Worktree holen (Arbeitsverzeichnis des Repos)
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if branch != "" {
+		if err := pullBranch(ctx, wt, auth, branch); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("pull %s failed: %v", branch, err)
+		}
+		return nil
+	}
+
+	// 1) main versuchen
+	errMain := pullBranch(ctx, wt, auth, "main")
+	if errMain == nil || errors.Is(errMain, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return errMain
+	}
+	// 2) fallback: master versuchen
+	errMaster := pullBranch(ctx, wt, auth, "master")
+	if errMaster == nil || errors.Is(errMaster, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+
+	return fmt.Errorf("pull main failed: %v; pull master failed: %v", errMain, errMaster)
+	// Wenn beide Branch-Pulls fehlschlagen
+}
+
+// pullBranch macht ein `git pull` für einen bestimmten Branch.
+//
+// Parameter:
+// - wt: Worktree des geöffneten Repos
+// - auth: Auth (nil = anonym)
+// - branch: Branchname
+//
+// Hinweise:
+// - Depth: 1 => shallow pull, schnell
+// - SingleBranch: true => nur diesen Branch aktualisieren
+func pullBranch(ctx context.Context, wt *git.Worktree, auth transport.AuthMethod, branch string) error {
+	return wt.PullContext(ctx, &git.PullOptions{
+		RemoteName:    "origin",
+		Auth:          auth,
+		SingleBranch:  true,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		Depth:         1,
+	})
+}
+
+// pushBranch pusht refs/heads/<branch> aus dem Mirror nach origin.
+// Kein Force: existiert der Branch remote schon mit anderem Stand, schlägt der Push fehl.
+func pushBranch(ctx context.Context, repoPath, branch string, auth transport.AuthMethod) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return err
+	}
+	spec := config.RefSpec("refs/heads/" + branch + ":refs/heads/" + branch)
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{spec},
+		Auth:       auth,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
@@ -1,29 +1,60 @@
 package main
 
 import (
+	"fmt"           // Gründe für unparsed Einträge formatieren
 	"io/fs"         // Typen für WalkDir Callback (fs.DirEntry)
 	"os"            // File lesen
 	"path"          // URL/Path handling (path.Base für URL-Pfade)
 	"path/filepath" // OS-spezifische Pfade (Join, WalkDir)
-	"regexp"        // Regex für url/version parsing
+	"regexp"        // Regex für Versionstokens in URLs/Tags
+	"sort"          // unparsed Einträge sortieren
 	"strings"       // Strings trimmen, suffix prüfen etc.
 )
 
-// ---- Regex für Version / URL in Formula Ruby Files ----
+// ---- Version / URL in Formula Ruby Files ----
 //
-// Hinweis: du hast reVersion aktuell auskommentiert.
-// Das bedeutet: du liest NICHT "version ..." Zeilen,
-// sondern leitest die Version nur aus der "url ..." ab.
-//
-// var reVersion = regexp.MustCompile(`(?m)^\s*version(?:\s*\(\s*)?\s*["']([^"']+)["']`)
-var reURL = regexp.MustCompile(`(?m)^\s*url\s+["']([^"']+)["']`)
+// Das Lesen von version/url/tag läuft über den block-bewussten Mini-Parser
+// (rubydsl.go), damit url Zeilen in head/resource/on_macos Blöcken nicht
+// versehentlich als stable url gelesen werden.
+// Scheitert der Parser an einem File, gibt es den alten Zeilen-Fallback
+// (extractVersionLines); solche Files listet der Report unter "line fallback".
+
+// versionSource sagt, woher eine extrahierte Version stammt (wird im Report angezeigt).
+type versionSource string
+
+const (
+	sourceNone    versionSource = ""        // keine Version gefunden
+	sourceVersion versionSource = "version" // expliziter version Stanza
+	sourceTag     versionSource = "tag"     // tag: Argument einer git url
+	sourceURL     versionSource = "url"     // aus dem Dateinamen der url abgeleitet
+)
 
-// localFormula beschreibt eine local tap formula, die wir gefunden haben.
+// localFormula beschreibt eine local tap formula (oder einen Cask), die wir gefunden haben.
 // - Version: extrahierte Version (z.B. 3.14.2 oder 20260107.0)
 // - Path: absoluter/relativer Pfad zum Ruby File in deinem Mirror/Repo
+// - Kind: kindFormula (Formula/*.rb) oder kindCask (Casks/*.rb)
+// - VersionSource: woher die Version stammt (version Stanza, git tag oder url)
+// - Tap / Prefix: aus welchem Tap der Eintrag kommt und welcher Prefix für den upstream Namen wegfällt
+// - ParseError: Parser-Fehler, wenn die Version aus dem Zeilen-Fallback stammt ("" = sauber geparst)
 type localFormula struct {
-	Version string
-	Path    string
+	Version       string
+	Path          string
+	Kind          kind
+	VersionSource versionSource
+	Tap           string
+	Prefix        string
+	ParseError    string
+}
+
+// unparsedEntry ist ein Tap File, aus dem wir keine Version lesen konnten.
+// Solche Einträge fallen nicht mehr still aus dem Audit, sondern landen im Report.
+// - Reason: kurzer, menschenlesbarer Grund (z.B. "git url without tag: and no version stanza")
+type unparsedEntry struct {
+	Tap    string
+	Name   string
+	Path   string
+	Kind   kind
+	Reason string
 }
 
 // loadFormulaEntries läuft durch repoPath/Formula und sammelt alle .rb Dateien.
@@ -32,15 +63,35 @@ type localFormula struct {
 // map[formulaName]localFormula
 //
 //	z.B. "gov-abseil" -> {Version:"20260107.0", Path:".../Formula/a/gov-abseil.rb"}
-func loadFormulaEntries(repoPath string) (map[string]localFormula, error) {
+//
+// plus alle Files ohne extrahierbare Version ([]unparsedEntry, sortiert nach Name).
+func loadFormulaEntries(repoPath string) (map[string]localFormula, []unparsedEntry, error) {
 	// Formel-Verzeichnis (Homebrew-typisch: <tap>/Formula)
-	formulaDir := filepath.Join(repoPath, "Formula")
+	return loadTapEntries(filepath.Join(repoPath, "Formula"), kindFormula)
+}
+
+// loadCaskEntries läuft durch repoPath/Casks und sammelt alle Cask .rb Dateien.
+// Viele Taps haben gar keine Casks: ein fehlendes Casks-Verzeichnis ist darum kein Fehler.
+//
+//	z.B. "gov-firefox" -> {Version:"128.0", Path:".../Casks/gov-firefox.rb", Kind:kindCask}
+func loadCaskEntries(repoPath string) (map[string]localFormula, []unparsedEntry, error) {
+	caskDir := filepath.Join(repoPath, "Casks")
+	exists, err := pathExists(caskDir)
+	if err != nil || !exists {
+		return map[string]localFormula{}, nil, err
+	}
+	return loadTapEntries(caskDir, kindCask)
+}
 
+// loadTapEntries ist der gemeinsame Scanner für Formula/ und Casks/.
+// k bestimmt, wie die Version aus dem File gelesen wird.
+func loadTapEntries(dir string, k kind) (map[string]localFormula, []unparsedEntry, error) {
 	// Output Map initialisieren
 	out := map[string]localFormula{}
+	var unparsed []unparsedEntry
 
-	// WalkDir traversiert rekursiv alle Dateien/Ordner im Formula-Verzeichnis
-	err := filepath.WalkDir(formulaDir, func(p string, d fs.DirEntry, err error) error {
+	// WalkDir traversiert rekursiv alle Dateien/Ordner im Verzeichnis
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
 		// Wenn WalkDir selbst auf einen Fehler läuft, müssen wir ihn zurückgeben,
 		// sonst werden Files "übersprungen" und du merkst es nicht.
 		if err != nil {
@@ -48,12 +99,12 @@ func loadFormulaEntries(repoPath string) (map[string]localFormula, error) {
 		}
 
 		// Wir interessieren uns nur für Dateien, nicht Ordner
-		// und nur Ruby formula files (*.rb)
+		// und nur Ruby files (*.rb)
 		if d.IsDir() || !strings.HasSuffix(d.Name(), ".rb") {
 			return nil
 		}
 
-		// formula Name ist der Filename ohne .rb
+		// Name ist der Filename ohne .rb
 		// Beispiel: gov-abseil.rb -> gov-abseil
 		name := strings.TrimSuffix(d.Name(), ".rb")
 
@@ -63,45 +114,276 @@ func loadFormulaEntries(repoPath string) (map[string]localFormula, error) {
 			return err
 		}
 
-		// Version extrahieren (aktuell: aus URL)
-		v := extractVersion(string(b), name)
+		// Version extrahieren (Formula: version Stanza / tag / url, Cask: aus dem version Stanza)
+		var (
+			v        string
+			src      versionSource
+			parseErr error
+		)
+		if k == kindCask {
+			v, src = extractCaskVersion(string(b)), sourceVersion
+		} else {
+			v, src, parseErr = extractVersion(string(b), name)
+		}
 
-		// Nur aufnehmen, wenn wir wirklich eine Version gefunden haben
-		if v != "" {
-			out[name] = localFormula{
-				Version: v, // extrahierte Version
-				Path:    p, // Pfad zum File (wichtig fürs Update/Overwrite)
-			}
+		// Ohne Version kein Vergleich möglich -> als unparsed mit Grund merken
+		if v == "" {
+			unparsed = append(unparsed, unparsedEntry{
+				Name:   name,
+				Path:   p,
+				Kind:   k,
+				Reason: noVersionReason(string(b), k),
+			})
+			return nil
+		}
+
+		out[name] = localFormula{
+			Version:       v, // extrahierte Version
+			Path:          p, // Pfad zum File (wichtig fürs Update/Overwrite)
+			Kind:          k,
+			VersionSource: src,
+		}
+		if parseErr != nil {
+			// Version kommt aus dem Zeilen-Fallback -> im Report als unsicher markieren
+			e := out[name]
+			e.ParseError = parseErr.Error()
+			out[name] = e
 		}
 		return nil
 	})
 
+	sort.Slice(unparsed, func(i, j int) bool { return unparsed[i].Name < unparsed[j].Name })
+
 	// WalkDir Fehler (oder nil) zurückgeben
-	return out, err
+	return out, unparsed, err
 }
 
-// extractVersion versucht aus dem Ruby File Content eine Version zu extrahieren.
-// Deine aktuelle Logik:
-// 1) version Zeile ist auskommentiert
-// 2) url Zeile matchen und daraus eine Version ableiten
+// noVersionReason erklärt, warum extractVersion / extractCaskVersion nichts gefunden haben.
+// Die Reihenfolge der Checks folgt der Priorität in extractVersion.
+func noVersionReason(content string, k kind) string {
+	if k == kindCask {
+		nodes, err := parseCask(content)
+		if err != nil {
+			return "parse error: " + err.Error()
+		}
+		if st := findStanza(nodes, "version"); st != nil {
+			if sym, ok := st.first().sym(); ok && sym == "latest" {
+				return "version :latest is not comparable"
+			}
+		}
+		return "no version stanza"
+	}
+
+	doc, err := parseFormula(content)
+	if err != nil {
+		return "parse error: " + err.Error()
+	}
+	st := findStanza(doc.stable(), "url")
+	if st == nil {
+		return "no url or version stanza"
+	}
+	u, ok := st.stringValue()
+	if !ok {
+		return "url is not a plain string literal"
+	}
+	if tag := st.kw("tag"); tag != nil {
+		t, _ := tag.str()
+		return fmt.Sprintf("git tag %q contains no version", t)
+	}
+	if isGitURL(st) {
+		return "git url without tag: and no version stanza"
+	}
+	return "cannot infer version from url " + u
+}
+
+// extractCaskVersion liest den version Stanza im cask Block.
+// Bei version :latest (Symbol) gibt es keine vergleichbare Version -> "".
+func extractCaskVersion(content string) string {
+	nodes, err := parseCask(content)
+	if err != nil {
+		return ""
+	}
+	if st := findStanza(nodes, "version"); st != nil {
+		if v, ok := st.stringValue(); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// extractVersion versucht aus dem Ruby File Content eine Version zu extrahieren
+// und gibt zusätzlich zurück, woher sie stammt.
+//
+// Priorität (wie Homebrew selbst), jeweils nur in der stable Spec:
+// 1) expliziter version Stanza:       version "1.2.3"
+// 2) tag: einer git url:              url "https://...git", tag: "v1.2.3", revision: "..."
+// 3) aus der url abgeleitet:          url ".../foo-1.2.3.tar.gz"
 //
-// Vorteil: funktioniert bei vielen Formulae, weil die URL meistens die Version enthält.
-// Nachteil: manchmal ist die Version nicht sauber in der URL oder endet mit ".tar" etc.
-func extractVersion(content, pkgName string) string {
-	// Wenn du später wieder "version ..." direkt unterstützen willst,
-	// kannst du den reVersion Block wieder aktivieren:
-	//
-	// if m := reVersion.FindStringSubmatch(content); len(m) == 2 {
-	//     return strings.TrimSpace(m[1])
-	// }
-
-	// 2) Sonst: url "..." Zeile finden
-	if m := reURL.FindStringSubmatch(content); len(m) == 2 {
-		// m[1] ist die URL aus der url "..."" Zeile
-		return inferVersionFromURL(m[1], pkgName)
-	}
-
-	// Wenn weder version noch url gefunden wird: keine Version
+// Bei einer git url ohne tag (nur revision:) gibt es nichts Ableitbares:
+// der Repo-Name in der URL ist keine Version.
+// Files, die der Parser nicht versteht, gehen über den Zeilen-Fallback (extractVersionLines);
+// parseErr ist dann der Parser-Fehler, damit der Report das Ergebnis als unsicher markieren kann.
+func extractVersion(content, pkgName string) (v string, src versionSource, parseErr error) {
+	doc, err := parseFormula(content)
+	if err != nil {
+		v, src := extractVersionLines(content, pkgName)
+		return v, src, err
+	}
+	v, src = stableVersion(doc, pkgName)
+	return v, src, nil
+}
+
+// stableVersion liest die Version aus der stable Spec eines geparsten Files (Priorität siehe extractVersion).
+func stableVersion(doc *formulaDoc, pkgName string) (string, versionSource) {
+	stable := doc.stable()
+
+	// 1) version "..." hat immer Vorrang
+	if st := findStanza(stable, "version"); st != nil {
+		if v, ok := st.stringValue(); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v), sourceVersion
+		}
+	}
+
+	// 2) + 3) stable url (inkl. Keyword-Argumente auf Folgezeilen)
+	st := findStanza(stable, "url")
+	if st == nil {
+		// Wenn weder version noch url gefunden wird: keine Version
+		return "", sourceNone
+	}
+	u, ok := st.stringValue()
+	if !ok {
+		return "", sourceNone
+	}
+
+	if tag := st.kw("tag"); tag != nil {
+		if t, ok := tag.str(); ok {
+			if v := versionFromTag(t, pkgName); v != "" {
+				return v, sourceTag
+			}
+		}
+	}
+
+	if isGitURL(st) {
+		return "", sourceNone
+	}
+
+	if v := inferVersionFromURL(u, pkgName); v != "" {
+		return v, sourceURL
+	}
+	return "", sourceNone
+}
+
+// ---- Zeilen-Fallback ----
+//
+// Für Files, an denen rubydsl.go scheitert (Ruby, das der Mini-Parser nicht kennt), gibt es
+// den alten zeilenbasierten Weg: erste version/url Zeile im File, egal in welchem Block.
+// Das kann eine url aus head/resource erwischen, verliert den Eintrag aber nicht ganz.
+
+// reLineVersion: expliziter version Stanza (version "1.2.3" / version("1.2.3"))
+// reLineURL:     erste url "..." Zeile
+// reLineTag:     tag: "v1.2.3" Argument einer git url (kann auf Folgezeilen stehen)
+// reLineGitUsing: using: :git Argument
+// reLineSHA256:  erste sha256 "..." Zeile
+var (
+	reLineVersion  = regexp.MustCompile(`(?m)^\s*version(?:\s*\(\s*)?\s*["']([^"']+)["']`)
+	reLineURL      = regexp.MustCompile(`(?m)^\s*url\s+["']([^"']+)["']`)
+	reLineTag      = regexp.MustCompile(`\btag:\s*["']([^"']+)["']`)
+	reLineGitUsing = regexp.MustCompile(`\busing:\s*:git\b`)
+	reLineSHA256   = regexp.MustCompile(`(?m)^\s*sha256\s+["']([^"']+)["']`)
+)
+
+// extractVersionLines ist extractVersion ohne Parser (gleiche Priorität, erste passende Zeile).
+func extractVersionLines(content, pkgName string) (string, versionSource) {
+	if m := reLineVersion.FindStringSubmatch(content); len(m) == 2 {
+		if v := strings.TrimSpace(m[1]); v != "" {
+			return v, sourceVersion
+		}
+	}
+
+	u, start, end, ok := lineURL(content)
+	if !ok {
+		return "", sourceNone
+	}
+	stmt := content[start:end]
+	if m := reLineTag.FindStringSubmatch(stmt); len(m) == 2 {
+		if v := versionFromTag(m[1], pkgName); v != "" {
+			return v, sourceTag
+		}
+	}
+	if strings.HasSuffix(u, ".git") || reLineGitUsing.MatchString(stmt) {
+		return "", sourceNone
+	}
+	if v := inferVersionFromURL(u, pkgName); v != "" {
+		return v, sourceURL
+	}
+	return "", sourceNone
+}
+
+// lineURL findet die erste url "..." Zeile samt Folgezeilen. Ruby setzt einen Aufruf fort,
+// solange die Zeile mit "," endet:
+//
+//	url "https://github.com/foo/bar.git",
+//	    tag:      "v1.2.3",
+//	    revision: "abc123"
+//
+// Rückgabe: url plus Statement als content[start:end] (end steht hinter dem Newline).
+func lineURL(content string) (u string, start, end int, ok bool) {
+	loc := reLineURL.FindStringSubmatchIndex(content)
+	if loc == nil {
+		return "", 0, 0, false
+	}
+	start = strings.LastIndexByte(content[:loc[3]], '\n') + 1
+	end = start
+	for {
+		nl := strings.IndexByte(content[end:], '\n')
+		if nl == -1 {
+			end = len(content)
+			break
+		}
+		line := strings.TrimSpace(content[end : end+nl])
+		end += nl + 1
+		if !strings.HasSuffix(line, ",") {
+			break
+		}
+	}
+	return content[loc[2]:loc[3]], start, end, true
+}
+
+// isGitURL: url "....git" oder url "...", using: :git
+func isGitURL(url *rbStanza) bool {
+	if u, ok := url.stringValue(); ok && strings.HasSuffix(u, ".git") {
+		return true
+	}
+	if using := url.kw("using"); using != nil {
+		if sym, ok := using.sym(); ok && sym == "git" {
+			return true
+		}
+	}
+	return false
+}
+
+// reTagVersion sucht in einem git Tag nach einer Version (mindestens "1.2" oder eine reine Zahl).
+var reTagVersion = regexp.MustCompile(`(\d+(?:\.\d+)*[A-Za-z0-9._-]*)$`)
+
+// versionFromTag macht aus einem git Tag eine Version.
+// Beispiele:
+// "v1.2.3"          -> "1.2.3"
+// "foo-1.2.3"       -> "1.2.3"
+// "release-2024.01" -> "2024.01"
+// "20260107.0"      -> "20260107.0"
+func versionFromTag(tag, pkgName string) string {
+	t := strings.TrimSpace(tag)
+	t = strings.TrimPrefix(t, pkgName+"-")
+	t = strings.TrimPrefix(t, pkgName+"_")
+	t = strings.TrimPrefix(t, "v")
+
+	// Prefix bis zur ersten Ziffer ignorieren (release-, rel_, version- ...)
+	if i := strings.IndexAny(t, "0123456789"); i > 0 {
+		t = t[i:]
+	}
+	if m := reTagVersion.FindStringSubmatch(t); len(m) == 2 && m[1] == t {
+		return t
+	}
 	return ""
 }
 
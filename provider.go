@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// ---- Git Provider: Pull Requests für Bitbucket, GitHub (inkl. Enterprise) und GitLab ----
+//
+// Der Provider wird aus der TAP_URL erkannt (detectProvider) oder per --provider gesetzt
+// (nötig z.B. für GitHub Enterprise / GitLab auf einem Host ohne "github"/"gitlab" im Namen).
+// Für den reinen Mirror (clone/pull) braucht es keinen Provider, nur Credentials.
+
+// pullRequest sind die Daten für einen neuen Pull/Merge Request (unabhängig vom Provider).
+type pullRequest struct {
+	title       string
+	description string
+	source      string // Branch mit dem Bump
+	target      string // Ziel-Branch (main/master)
+}
+
+// gitProvider kapselt alles, was pro Hosting-Provider anders ist.
+type gitProvider interface {
+	// name ist der Wert für --provider, z.B. "github"
+	name() string
+	// defaultUser ist der BasicAuth Username, wenn nur ein Token bekannt ist
+	defaultUser() string
+	// envCredential liest die Provider-spezifischen Env Variablen (nil = nicht gesetzt)
+	envCredential() *credential
+	// createPullRequest öffnet den PR und liefert die Web-URL; apiBase "" = Default des Providers
+	createPullRequest(ctx context.Context, client *http.Client, apiBase string, cred *credential, pr pullRequest) (string, error)
+}
+
+// detectProvider bestimmt den Provider für tapURL.
+//
+// - override ("bitbucket", "github", "gitlab"): erzwingt den Provider
+// - sonst: bitbucket.org, /scm/ im Pfad oder SSH Port 7999 -> Bitbucket
+// - Host mit "github" -> GitHub, Host mit "gitlab" -> GitLab (nur geraten, darum ohne Provider-Env Tokens)
+//
+// Unbekannter Host ohne override: nil, nil (Mirror geht, PRs nicht).
+func detectProvider(tapURL, override string) (gitProvider, error) {
+	u, err := parseRemoteURL(tapURL)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(u.Hostname())
+
+	name := strings.ToLower(override)
+	if name == "" {
+		switch {
+		case host == "bitbucket.org" || strings.Contains(u.Path, "/scm/") || (u.Scheme == "ssh" && u.Port() == "7999"):
+			name = "bitbucket"
+		case strings.Contains(host, "github"):
+			name = "github"
+		case strings.Contains(host, "gitlab"):
+			name = "gitlab"
+		default:
+			return nil, nil
+		}
+	}
+
+	var (
+		p    gitProvider
+		perr error
+	)
+	switch name {
+	case "bitbucket":
+		p, perr = parseBitbucketRepo(u)
+	case "github":
+		p, perr = parseGitHubRepo(u)
+	case "gitlab":
+		p, perr = parseGitLabRepo(u)
+	default:
+		return nil, fmt.Errorf("unknown --provider %q (want bitbucket, github or gitlab)", override)
+	}
+	if perr != nil {
+		return nil, perr
+	}
+	return p, nil
+}
+
+// apiOrigin liefert scheme://host der REST API zur Clone-URL.
+// http nur, wenn die Clone-URL selbst http ist (lokale Testserver); sonst https.
+// Bei SSH URLs fällt der Port weg (7999/22 ist der SSH Port, nicht der der API).
+func apiOrigin(u *url.URL) string {
+	switch u.Scheme {
+	case "http":
+		return "http://" + u.Host
+	case "https":
+		return "https://" + u.Host
+	}
+	return "https://" + u.Hostname()
+}
+
+// repoPathParts liefert die Pfadsegmente der Clone-URL ohne ".git", z.B. ["org", "homebrew-tap"].
+func repoPathParts(u *url.URL) []string {
+	return strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+}
+
+// tokenFromEnv liefert das erste gesetzte Token aus names als credential.
+func tokenFromEnv(user string, names ...string) *credential {
+	for _, n := range names {
+		if tok := os.Getenv(n); tok != "" {
+			return &credential{username: user, password: tok, source: "env " + n}
+		}
+	}
+	return nil
+}
+
+// postJSON schickt payload als JSON an endpoint und dekodiert eine 2xx Antwort nach out.
+// Non-2xx wird zu httpStatusError plus Fehlermeldung des Providers.
+func postJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", &httpStatusError{what: "provider api", url: endpoint, status: resp.StatusCode}, apiErrorMessage(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode pull request response: %w", err)
+	}
+	return nil
+}
+
+// apiErrorMessage holt die Fehlermeldung aus einer Provider-Antwort:
+// - Bitbucket Cloud: {"error":{"message"}}, Data Center: {"errors":[{"message"}]}
+// - GitHub: {"message", "errors":[{"message"}]}, GitLab: {"message"} (String oder Objekt)
+// Sonst den gekürzten Body.
+func apiErrorMessage(body []byte) string {
+	var e struct {
+		Message json.RawMessage `json:"message"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &e) == nil {
+		var parts []string
+		var msg string
+		if json.Unmarshal(e.Message, &msg) == nil && msg != "" {
+			parts = append(parts, msg)
+		} else if len(e.Message) > 0 && string(e.Message) != "null" {
+			parts = append(parts, string(e.Message))
+		}
+		if e.Error.Message != "" {
+			parts = append(parts, e.Error.Message)
+		}
+		for _, x := range e.Errors {
+			if x.Message != "" {
+				parts = append(parts, x.Message)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, "; ")
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return msg
+}
+
+// publishBump pusht den Bump-Branch und öffnet optional (openPR) den Pull Request
+// gegen den Branch, auf dem der Mirror steht (res.baseBranch).
+//
+// auth ist dieselbe Git Auth wie für den Mirror (HTTPS oder SSH); cred ist für die Provider API.
+func publishBump(ctx context.Context, client *http.Client, repoPath string, res commitResult, bumped []bumpedFormula, openPR bool, p gitProvider, auth transport.AuthMethod, cred *credential, apiBase string) error {
+	if err := pushBranch(ctx, repoPath, res.branch, auth); err != nil {
+		return fmt.Errorf("push %s: %w", res.branch, err)
+	}
+	fmt.Fprintf(updateOut, "Pushed branch %s to origin.\n", res.branch)
+	if !openPR {
+		fmt.Fprintln(updateOut)
+		return nil
+	}
+	if cred == nil {
+		return fmt.Errorf("create pull request: no credentials for the %s API", p.name())
+	}
+
+	prURL, err := p.createPullRequest(ctx, client, apiBase, cred, pullRequest{
+		title:       bumpTitle(bumped),
+		description: bumpPRDescription(bumped),
+		source:      res.branch,
+		target:      res.baseBranch,
+	})
+	if err != nil {
+		return fmt.Errorf("create pull request: %w", err)
+	}
+	fmt.Fprintf(updateOut, "Opened pull request: %s\n\n", prURL)
+	return nil
+}
+
+// bumpPRDescription fasst die Version-Änderungen für die PR Beschreibung zusammen (Markdown).
+func bumpPRDescription(bumped []bumpedFormula) string {
+	var b strings.Builder
+	b.WriteString("Automated bump by tap-version-audit.\n\n")
+	b.WriteString("| Formula | Upstream | Old | New | Source |\n|---|---|---|---|---|\n")
+	for _, f := range bumped {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+			mdCell(f.privateName), mdCell(f.upstream), mdCell(f.oldVersion), mdCell(f.newVersion), mdCell(f.sourceURL))
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ---- Bitbucket (Cloud und Data Center) ----
+//
+// Bitbucket gibt es in zwei Varianten mit unterschiedlicher REST API:
+// - Cloud (bitbucket.org):        POST {api}/repositories/{workspace}/{repo}/pullrequests
+// - Data Center / Server (eigener Host, Clone-URL mit /scm/): POST {api}/projects/{key}/repos/{slug}/pull-requests
+// Welche Variante gilt, leiten wir aus der TAP_URL ab.
+
+// bitbucketRepo beschreibt das Tap-Repo aus Sicht der Bitbucket REST API.
+type bitbucketRepo struct {
+	cloud   bool   // true = bitbucket.org, false = Data Center
+	owner   string // Cloud: Workspace, DC: Project Key
+	slug    string // Repo Slug
+	apiBase string // Default API Basis-URL ohne abschliessenden "/"
+}
+
+// parseBitbucketRepo zerlegt die Clone-URL des Taps:
+// - https://[user@]bitbucket.org/<workspace>/<repo>.git (bzw. git@bitbucket.org:<workspace>/<repo>.git)
+// - https://[user@]<host>[/<context>]/scm/<project>/<repo>.git
+// - ssh://git@<host>:7999/<project>/<repo>.git (Data Center via SSH, API ohne Context-Pfad)
+func parseBitbucketRepo(u *url.URL) (*bitbucketRepo, error) {
+	parts := repoPathParts(u)
+
+	if strings.EqualFold(u.Hostname(), "bitbucket.org") {
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("TAP_URL %q: want https://bitbucket.org/<workspace>/<repo>.git", u.Redacted())
+		}
+		return &bitbucketRepo{cloud: true, owner: parts[0], slug: parts[1], apiBase: "https://api.bitbucket.org/2.0"}, nil
+	}
+
+	// Data Center: .../scm/<project>/<repo>, alles davor ist der Context-Pfad der Instanz
+	if n := len(parts); n >= 3 && parts[n-3] == "scm" {
+		ctx := strings.Join(parts[:n-3], "/")
+		if ctx != "" {
+			ctx = "/" + ctx
+		}
+		return &bitbucketRepo{
+			owner:   parts[n-2],
+			slug:    parts[n-1],
+			apiBase: apiOrigin(u) + ctx + "/rest/api/1.0",
+		}, nil
+	}
+	if n := len(parts); u.Scheme == "ssh" && n == 2 {
+		return &bitbucketRepo{owner: parts[0], slug: parts[1], apiBase: apiOrigin(u) + "/rest/api/1.0"}, nil
+	}
+	return nil, fmt.Errorf("TAP_URL %q: want a bitbucket.org or Data Center (/scm/<project>/<repo>.git) clone URL", u.Redacted())
+}
+
+func (r *bitbucketRepo) name() string { return "bitbucket" }
+
+// defaultUser: Bitbucket Access Tokens (Repo/Projekt/Workspace) nutzen "x-token-auth".
+func (r *bitbucketRepo) defaultUser() string { return "x-token-auth" }
+
+// envCredential liest BITBUCKET_USER / BITBUCKET_TOKEN (beide nötig; App Passwords brauchen den echten User).
+func (r *bitbucketRepo) envCredential() *credential {
+	user, token := os.Getenv("BITBUCKET_USER"), os.Getenv("BITBUCKET_TOKEN")
+	if user == "" || token == "" {
+		return nil
+	}
+	return &credential{username: user, password: token, source: "env BITBUCKET_USER/BITBUCKET_TOKEN"}
+}
+
+// authHeader: reine Tokens (Username x-token-auth, z.B. aus TAP_TOKEN_<HOST>, netrc oder dem
+// Credential Helper) sind Access Tokens -> Bearer; echte User/App Password Paare -> Basic.
+func (r *bitbucketRepo) authHeader(cred *credential) string {
+	if cred.username == r.defaultUser() {
+		return "Bearer " + cred.password
+	}
+	return basicAuthHeader(cred)
+}
+
+// createPullRequest öffnet den Pull Request und liefert die Web-URL zurück.
+func (r *bitbucketRepo) createPullRequest(ctx context.Context, client *http.Client, apiBase string, cred *credential, pr pullRequest) (string, error) {
+	if apiBase == "" {
+		apiBase = r.apiBase
+	}
+	apiBase = strings.TrimSuffix(apiBase, "/")
+
+	var (
+		endpoint string
+		payload  any
+	)
+	if r.cloud {
+		endpoint = fmt.Sprintf("%s/repositories/%s/%s/pullrequests", apiBase, url.PathEscape(r.owner), url.PathEscape(r.slug))
+		payload = map[string]any{
+			"title":               pr.title,
+			"description":         pr.description,
+			"source":              map[string]any{"branch": map[string]string{"name": pr.source}},
+			"destination":         map[string]any{"branch": map[string]string{"name": pr.target}},
+			"close_source_branch": true,
+		}
+	} else {
+		endpoint = fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests", apiBase, url.PathEscape(r.owner), url.PathEscape(r.slug))
+		ref := func(branch string) map[string]any {
+			return map[string]any{
+				"id": "refs/heads/" + branch,
+				"repository": map[string]any{
+					"slug":    r.slug,
+					"project": map[string]string{"key": r.owner},
+				},
+			}
+		}
+		payload = map[string]any{
+			"title":       pr.title,
+			"description": pr.description,
+			"fromRef":     ref(pr.source),
+			"toRef":       ref(pr.target),
+		}
+	}
+
+	// Antwort: Cloud links.html.href, DC links.self[0].href
+	var created struct {
+		ID    int `json:"id"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+			Self any `json:"self"`
+		} `json:"links"`
+	}
+	h := http.Header{}
+	h.Set("Authorization", r.authHeader(cred))
+	if err := postJSON(ctx, client, endpoint, h, payload, &created); err != nil {
+		return "", err
+	}
+
+	if created.Links.HTML.Href != "" {
+		return created.Links.HTML.Href, nil
+	}
+	if self, ok := created.Links.Self.([]any); ok && len(self) > 0 {
+		if m, ok := self[0].(map[string]any); ok {
+			if href, _ := m["href"].(string); href != "" {
+				return href, nil
+			}
+		}
+	}
+	return fmt.Sprintf("pull request #%d", created.ID), nil
+}
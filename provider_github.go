@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ---- GitHub (github.com und GitHub Enterprise Server) ----
+//
+// Pull Request: POST {api}/repos/{owner}/{repo}/pulls
+// API Basis: github.com -> https://api.github.com, Enterprise -> https://<host>/api/v3
+
+// githubRepo beschreibt das Tap-Repo aus Sicht der GitHub REST API.
+type githubRepo struct {
+	owner   string
+	repo    string
+	apiBase string
+}
+
+// parseGitHubRepo zerlegt https://<host>/<owner>/<repo>.git.
+func parseGitHubRepo(u *url.URL) (*githubRepo, error) {
+	parts := repoPathParts(u)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("TAP_URL %q: want https://<host>/<owner>/<repo>.git for GitHub", u.Redacted())
+	}
+	apiBase := apiOrigin(u) + "/api/v3"
+	if strings.EqualFold(u.Hostname(), "github.com") {
+		apiBase = "https://api.github.com"
+	}
+	return &githubRepo{owner: parts[0], repo: parts[1], apiBase: apiBase}, nil
+}
+
+func (r *githubRepo) name() string { return "github" }
+
+// defaultUser: GitHub ignoriert den Username bei Tokens, er darf nur nicht leer sein.
+func (r *githubRepo) defaultUser() string { return "x-access-token" }
+
+// envCredential liest GITHUB_TOKEN bzw. GH_TOKEN (wie die gh CLI).
+func (r *githubRepo) envCredential() *credential {
+	return tokenFromEnv(r.defaultUser(), "GITHUB_TOKEN", "GH_TOKEN")
+}
+
+// createPullRequest öffnet den Pull Request und liefert html_url zurück.
+func (r *githubRepo) createPullRequest(ctx context.Context, client *http.Client, apiBase string, cred *credential, pr pullRequest) (string, error) {
+	if apiBase == "" {
+		apiBase = r.apiBase
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls", strings.TrimSuffix(apiBase, "/"), url.PathEscape(r.owner), url.PathEscape(r.repo))
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer "+cred.password)
+	h.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := postJSON(ctx, client, endpoint, h, map[string]any{
+		"title": pr.title,
+		"body":  pr.description,
+		"head":  pr.source,
+		"base":  pr.target,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	if created.HTMLURL != "" {
+		return created.HTMLURL, nil
+	}
+	return fmt.Sprintf("pull request #%d", created.Number), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ---- GitLab (gitlab.com und self-managed) ----
+//
+// Merge Request: POST {api}/projects/{url-encoded path}/merge_requests
+// API Basis: https://<host>/api/v4. Der Projektpfad darf Subgroups enthalten (group/sub/repo).
+
+// gitlabRepo beschreibt das Tap-Repo aus Sicht der GitLab REST API.
+type gitlabRepo struct {
+	project string // z.B. "platform/brew/homebrew-tap"
+	apiBase string
+}
+
+// parseGitLabRepo zerlegt https://<host>/<group>[/<subgroup>...]/<repo>.git.
+func parseGitLabRepo(u *url.URL) (*gitlabRepo, error) {
+	parts := repoPathParts(u)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("TAP_URL %q: want https://<host>/<group>/<repo>.git for GitLab", u.Redacted())
+	}
+	return &gitlabRepo{
+		project: strings.Join(parts, "/"),
+		apiBase: apiOrigin(u) + "/api/v4",
+	}, nil
+}
+
+func (r *gitlabRepo) name() string { return "gitlab" }
+
+// defaultUser: GitLab akzeptiert Tokens mit "oauth2" als Username.
+func (r *gitlabRepo) defaultUser() string { return "oauth2" }
+
+// envCredential liest GITLAB_TOKEN.
+func (r *gitlabRepo) envCredential() *credential {
+	return tokenFromEnv(r.defaultUser(), "GITLAB_TOKEN")
+}
+
+// createPullRequest öffnet den Merge Request und liefert web_url zurück.
+func (r *gitlabRepo) createPullRequest(ctx context.Context, client *http.Client, apiBase string, cred *credential, pr pullRequest) (string, error) {
+	if apiBase == "" {
+		apiBase = r.apiBase
+	}
+	endpoint := fmt.Sprintf("%s/projects/%s/merge_requests", strings.TrimSuffix(apiBase, "/"), url.PathEscape(r.project))
+
+	h := http.Header{}
+	h.Set("PRIVATE-TOKEN", cred.password)
+
+	var created struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	err := postJSON(ctx, client, endpoint, h, map[string]any{
+		"title":                pr.title,
+		"description":          pr.description,
+		"source_branch":        pr.source,
+		"target_branch":        pr.target,
+		"remove_source_branch": true,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	if created.WebURL != "" {
+		return created.WebURL, nil
+	}
+	return fmt.Sprintf("merge request !%d", created.IID), nil
+}
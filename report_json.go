@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// jsonSchemaVersion wird erhöht, sobald sich das JSON Schema inkompatibel ändert
+// (Felder entfernen/umbenennen). Neue Felder sind kein Bump.
+const jsonSchemaVersion = 1
+
+// ---- JSON Schema (--format json) ----
+//
+// Die report Typen haben bewusst unexported Felder; für JSON gibt es eigene
+// Structs mit festen Feldnamen, damit interne Umbauten das Schema nicht brechen.
+// Das Schema ist im README dokumentiert.
+
+type jsonReport struct {
+	SchemaVersion int             `json:"schema_version"`
+	GeneratedAt   string          `json:"generated_at"`
+	Incomplete    bool            `json:"incomplete"`
+	Reason        string          `json:"incomplete_reason,omitempty"`
+	Counts        jsonCounts      `json:"counts"`
+	Taps          []jsonTap       `json:"taps"`
+	Duplicates    []jsonDuplicate `json:"duplicates"`
+	Behind        []jsonBehind    `json:"behind"`
+	NotFound      []jsonNotFound  `json:"not_found"`
+	Errors        []jsonLookupErr `json:"errors"`
+	Unparsed      []jsonUnparsed  `json:"unparsed"`
+	LineFallback  []jsonUnparsed  `json:"line_fallback"`
+	Ignored       []jsonIgnored   `json:"ignored"`
+	NotChecked    []jsonNotFound  `json:"not_checked"`
+}
+
+type jsonCounts struct {
+	Formulae     int `json:"formulae"`
+	Casks        int `json:"casks"`
+	Behind       int `json:"behind"`
+	NotFound     int `json:"not_found"`
+	Errors       int `json:"errors"`
+	Unparsed     int `json:"unparsed"`
+	LineFallback int `json:"line_fallback"`
+	Duplicates   int `json:"duplicates"`
+	Ignored      int `json:"ignored"`
+	Retries      int `json:"retries"`
+	NotChecked   int `json:"not_checked"`
+}
+
+// jsonTap sind die Zähler eines Taps (duplicates: Duplikate, an denen der Tap beteiligt ist).
+type jsonTap struct {
+	Name   string     `json:"name"`
+	Counts jsonCounts `json:"counts"`
+}
+
+type jsonDuplicate struct {
+	Upstream string         `json:"upstream"`
+	Kind     string         `json:"kind"`
+	Entries  []jsonDupEntry `json:"entries"`
+}
+
+type jsonDupEntry struct {
+	Tap  string `json:"tap"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type jsonBehind struct {
+	Tap             string `json:"tap"`
+	Name            string `json:"name"`
+	Upstream        string `json:"upstream"`
+	Kind            string `json:"kind"`
+	PrivateVersion  string `json:"private_version"`
+	PrivateSource   string `json:"private_version_source"`
+	UpstreamVersion string `json:"upstream_version"`
+	Path            string `json:"path"`
+	Retries         int    `json:"retries"`
+}
+
+type jsonNotFound struct {
+	Tap      string `json:"tap"`
+	Name     string `json:"name"`
+	Upstream string `json:"upstream"`
+	Kind     string `json:"kind"`
+	Retries  int    `json:"retries"`
+}
+
+type jsonLookupErr struct {
+	Tap      string    `json:"tap"`
+	Name     string    `json:"name"`
+	Upstream string    `json:"upstream"`
+	Kind     string    `json:"kind"`
+	Cause    jsonCause `json:"cause"`
+	Retries  int       `json:"retries"`
+}
+
+type jsonUnparsed struct {
+	Tap    string `json:"tap"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type jsonIgnored struct {
+	Tap  string `json:"tap"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+// jsonCause beschreibt die Ursache eines Lookup-Fehlers.
+// Type ist einer von: "http_status", "network", "decode", "other".
+// HTTPStatus ist nur bei "http_status" gesetzt.
+type jsonCause struct {
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+}
+
+// writeJSONReport schreibt den kompletten Report als JSON (eingerückt) nach w.
+// Leere Listen werden als [] und nicht als null ausgegeben, damit Consumer nicht
+// zwischen "fehlt" und "leer" unterscheiden müssen.
+func writeJSONReport(w io.Writer, rep report) error {
+	out := jsonReport{
+		SchemaVersion: jsonSchemaVersion,
+		GeneratedAt:   rep.generatedAt.Format(time.RFC3339),
+		Incomplete:    rep.incomplete != "",
+		Reason:        rep.incomplete,
+		Counts:        countsOf(rep),
+		Taps:          []jsonTap{},
+		Duplicates:    []jsonDuplicate{},
+		Behind:        []jsonBehind{},
+		NotFound:      []jsonNotFound{},
+		Errors:        []jsonLookupErr{},
+		Unparsed:      []jsonUnparsed{},
+		LineFallback:  []jsonUnparsed{},
+		Ignored:       []jsonIgnored{},
+		NotChecked:    []jsonNotFound{},
+	}
+
+	for _, name := range rep.taps {
+		out.Taps = append(out.Taps, jsonTap{Name: name, Counts: countsOf(rep.forTap(name))})
+	}
+	for _, d := range rep.duplicates {
+		jd := jsonDuplicate{Upstream: d.upstream, Kind: d.kind.String()}
+		for _, e := range d.entries {
+			jd.Entries = append(jd.Entries, jsonDupEntry{Tap: e.tap, Name: e.name, Path: e.path})
+		}
+		out.Duplicates = append(out.Duplicates, jd)
+	}
+
+	for _, r := range rep.behind {
+		out.Behind = append(out.Behind, jsonBehind{
+			Tap:             r.tap,
+			Name:            r.privateName,
+			Upstream:        r.upstream,
+			Kind:            r.kind.String(),
+			PrivateVersion:  r.privateVer,
+			PrivateSource:   string(r.privateSrc),
+			UpstreamVersion: r.upstreamVer,
+			Path:            r.privatePath,
+			Retries:         r.retries,
+		})
+	}
+	for _, nf := range rep.notFound {
+		out.NotFound = append(out.NotFound, jsonNotFound{
+			Tap:      nf.tap,
+			Name:     nf.privateName,
+			Upstream: nf.upstream,
+			Kind:     nf.kind.String(),
+			Retries:  nf.retries,
+		})
+	}
+	for _, nc := range rep.notChecked {
+		out.NotChecked = append(out.NotChecked, jsonNotFound{
+			Tap:      nc.tap,
+			Name:     nc.privateName,
+			Upstream: nc.upstream,
+			Kind:     nc.kind.String(),
+			Retries:  nc.retries,
+		})
+	}
+	for _, le := range rep.errorsList {
+		out.Errors = append(out.Errors, jsonLookupErr{
+			Tap:      le.tap,
+			Name:     le.privateName,
+			Upstream: le.upstream,
+			Kind:     le.kind.String(),
+			Cause:    classifyCause(le.err),
+			Retries:  le.retries,
+		})
+	}
+
+	for _, u := range rep.unparsed {
+		out.Unparsed = append(out.Unparsed, jsonUnparsed{
+			Tap:    u.Tap,
+			Name:   u.Name,
+			Kind:   u.Kind.String(),
+			Path:   u.Path,
+			Reason: u.Reason,
+		})
+	}
+
+	for _, u := range rep.lineFallback {
+		out.LineFallback = append(out.LineFallback, jsonUnparsed{
+			Tap:    u.Tap,
+			Name:   u.Name,
+			Kind:   u.Kind.String(),
+			Path:   u.Path,
+			Reason: u.Reason,
+		})
+	}
+
+	for _, ig := range rep.ignored {
+		out.Ignored = append(out.Ignored, jsonIgnored{Tap: ig.tap, Name: ig.name, Kind: ig.kind.String(), Path: ig.path})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// countsOf zählt die Listen eines Reports (gesamt oder pro Tap).
+func countsOf(rep report) jsonCounts {
+	return jsonCounts{
+		Formulae:     rep.privateCount,
+		Casks:        rep.caskCount,
+		Behind:       len(rep.behind),
+		NotFound:     len(rep.notFound),
+		Errors:       len(rep.errorsList),
+		Unparsed:     len(rep.unparsed),
+		LineFallback: len(rep.lineFallback),
+		Duplicates:   len(rep.duplicates),
+		Ignored:      len(rep.ignored),
+		Retries:      rep.retryCount(),
+		NotChecked:   len(rep.notChecked),
+	}
+}
+
+// classifyCause ordnet einen Lookup-Fehler einer groben Kategorie zu.
+func classifyCause(err error) jsonCause {
+	c := jsonCause{Type: "other", Message: err.Error()}
+
+	var statusErr *httpStatusError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &statusErr):
+		c.Type = "http_status"
+		c.HTTPStatus = statusErr.status
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF):
+		c.Type = "decode"
+	case errors.As(err, &netErr):
+		// *url.Error implementiert net.Error -> deckt Timeouts, DNS, Connection refused ab
+		c.Type = "network"
+	}
+	return c
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ---- JUnit XML (--junit <file>) ----
+//
+// Jeder private Eintrag wird ein <testcase>:
+// - aktuell      -> passed (kein Child-Element)
+// - behind       -> <failure> mit "private -> upstream"
+// - not found    -> <skipped>
+// - not checked  -> <skipped type="not_checked"> (Run per Ctrl-C / --timeout abgebrochen)
+// - Lookup Error -> <error>
+// - unparsed     -> <error type="unparsed"> (keine Version im File gefunden)
+//
+// Formulae und Casks landen in eigenen <testsuite> Elementen; bei mehreren Taps
+// gibt es die pro Tap (tap-version-audit.<tap>.formulae / .casks).
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr"`
+	Tests   int              `xml:"tests,attr"`
+	Fail    int              `xml:"failures,attr"`
+	Errors  int              `xml:"errors,attr"`
+	Skipped int              `xml:"skipped,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name    string          `xml:"name,attr"`
+	Tests   int             `xml:"tests,attr"`
+	Fail    int             `xml:"failures,attr"`
+	Errors  int             `xml:"errors,attr"`
+	Skipped int             `xml:"skipped,attr"`
+	Cases   []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnitReport schreibt den Report als JUnit XML nach path.
+func writeJUnitReport(path string, rep report) error {
+	out := junitTestSuites{Name: "tap-version-audit"}
+	if len(rep.taps) > 1 {
+		for _, name := range rep.taps {
+			out.addSuites(junitSuites(rep.forTap(name), "tap-version-audit."+name))
+		}
+	} else {
+		out.addSuites(junitSuites(rep, "tap-version-audit"))
+	}
+
+	b, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append([]byte(xml.Header), b...)
+	b = append(b, '\n')
+	return os.WriteFile(path, b, 0o644)
+}
+
+// junitSuites baut die Formula- und Cask-Suite eines (gefilterten) Reports.
+func junitSuites(rep report, prefix string) []junitTestSuite {
+	suites := map[kind]*junitTestSuite{
+		kindFormula: {Name: prefix + ".formulae"},
+		kindCask:    {Name: prefix + ".casks"},
+	}
+
+	for _, r := range rep.upToDate {
+		suites[r.kind].add(junitTestCase{Name: r.privateName, ClassName: r.kind.String()})
+	}
+	for _, r := range rep.behind {
+		msg := fmt.Sprintf("%s -> %s", r.privateVer, r.upstreamVer)
+		suites[r.kind].add(junitTestCase{
+			Name:      r.privateName,
+			ClassName: r.kind.String(),
+			Failure: &junitMessage{
+				Message: msg,
+				Type:    "behind",
+				Body:    fmt.Sprintf("%s (upstream: %s): %s\n%s", r.privateName, r.upstream, msg, r.privatePath),
+			},
+		})
+	}
+	for _, nf := range rep.notFound {
+		suites[nf.kind].add(junitTestCase{
+			Name:      nf.privateName,
+			ClassName: nf.kind.String(),
+			Skipped:   &junitMessage{Message: "not found upstream (searched: " + nf.upstream + ")"},
+		})
+	}
+	for _, nc := range rep.notChecked {
+		suites[nc.kind].add(junitTestCase{
+			Name:      nc.privateName,
+			ClassName: nc.kind.String(),
+			Skipped:   &junitMessage{Message: "not checked: " + rep.incomplete, Type: "not_checked"},
+		})
+	}
+	for _, le := range rep.errorsList {
+		suites[le.kind].add(junitTestCase{
+			Name:      le.privateName,
+			ClassName: le.kind.String(),
+			Error: &junitMessage{
+				Message: le.err.Error(),
+				Type:    classifyCause(le.err).Type,
+				Body:    le.String(),
+			},
+		})
+	}
+
+	for _, u := range rep.unparsed {
+		suites[u.Kind].add(junitTestCase{
+			Name:      u.Name,
+			ClassName: u.Kind.String(),
+			Error: &junitMessage{
+				Message: u.Reason,
+				Type:    "unparsed",
+				Body:    u.Path,
+			},
+		})
+	}
+
+	var out []junitTestSuite
+	for _, k := range []kind{kindFormula, kindCask} {
+		s := suites[k]
+		// leere Cask-Suite weglassen, wenn der Tap keine Casks hat
+		if s.Tests == 0 && k == kindCask {
+			continue
+		}
+		// innerhalb der Suite alphabetisch, egal welcher Status
+		sort.SliceStable(s.Cases, func(i, j int) bool { return s.Cases[i].Name < s.Cases[j].Name })
+		out = append(out, *s)
+	}
+	return out
+}
+
+// addSuites hängt Suites an und summiert ihre Zähler im Root-Element.
+func (ts *junitTestSuites) addSuites(suites []junitTestSuite) {
+	for _, s := range suites {
+		ts.Tests += s.Tests
+		ts.Fail += s.Fail
+		ts.Errors += s.Errors
+		ts.Skipped += s.Skipped
+		ts.Suites = append(ts.Suites, s)
+	}
+}
+
+// add hängt einen Testcase an und zählt ihn in der passenden Kategorie mit.
+func (s *junitTestSuite) add(tc junitTestCase) {
+	s.Tests++
+	switch {
+	case tc.Failure != nil:
+		s.Fail++
+	case tc.Error != nil:
+		s.Errors++
+	case tc.Skipped != nil:
+		s.Skipped++
+	}
+	s.Cases = append(s.Cases, tc)
+}
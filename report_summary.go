@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---- Markdown / HTML Zusammenfassung (--report-file) ----
+//
+// Für Tickets, PR Kommentare und statische Pages:
+// - Tabelle der behind Einträge (Formulae und Casks getrennt)
+// - not found / Errors als aufklappbare <details> Sektionen
+// - Zeitpunkt des Runs
+//
+// Das Format ergibt sich aus der Dateiendung (.md/.markdown oder .html/.htm).
+
+type summaryFormat int
+
+const (
+	summaryMarkdown summaryFormat = iota
+	summaryHTML
+)
+
+// behindTitles sind die Überschriften der behind Tabellen pro Art.
+var behindTitles = map[kind]string{
+	kindFormula: "Behind upstream",
+	kindCask:    "Casks behind upstream",
+}
+
+// summaryFormatFor leitet das Format aus der Dateiendung ab.
+func summaryFormatFor(path string) (summaryFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return summaryMarkdown, nil
+	case ".html", ".htm":
+		return summaryHTML, nil
+	default:
+		return 0, fmt.Errorf("--report-file %q: unknown extension (want .md or .html)", path)
+	}
+}
+
+// writeSummaryReport rendert den Report als Markdown oder HTML nach path.
+func writeSummaryReport(path string, rep report) error {
+	f, err := summaryFormatFor(path)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if f == summaryHTML {
+		out, err = renderHTMLSummary(rep)
+		if err != nil {
+			return err
+		}
+	} else {
+		out = []byte(renderMarkdownSummary(rep))
+	}
+	return os.WriteFile(path, out, 0o644)
+}
+
+// renderMarkdownSummary baut die Markdown Variante (GitHub/Bitbucket flavoured).
+// Bei mehreren Taps: Übersicht pro Tap, Duplikate, dann ein Abschnitt pro Tap.
+func renderMarkdownSummary(rep report) string {
+	var b strings.Builder
+
+	b.WriteString("# Tap Version Audit\n\n")
+	fmt.Fprintf(&b, "Run: %s\n\n", rep.generatedAt.Format(time.RFC3339))
+	if rep.incomplete != "" {
+		fmt.Fprintf(&b, "> **Incomplete:** %s; %d entries not checked.\n\n", mdCell(rep.incomplete), len(rep.notChecked))
+	}
+
+	if len(rep.taps) <= 1 {
+		writeMarkdownTap(&b, rep, "##")
+		return b.String()
+	}
+
+	b.WriteString("| Tap | Formulae | Casks | Behind | Not found | Unparsed | Errors |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, name := range rep.taps {
+		t := rep.forTap(name)
+		fmt.Fprintf(&b, "| `%s` | %d | %d | %d | %d | %d | %d |\n",
+			name, t.privateCount, t.caskCount, len(t.behind), len(t.notFound), len(t.unparsed), len(t.errorsList))
+	}
+	b.WriteString("\n")
+
+	if len(rep.duplicates) > 0 {
+		fmt.Fprintf(&b, "## Duplicates across taps (%d)\n\n", len(rep.duplicates))
+		for _, d := range rep.duplicates {
+			fmt.Fprintf(&b, "- %s\n", mdCell(d.String()))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, name := range rep.taps {
+		fmt.Fprintf(&b, "## Tap `%s`\n\n", name)
+		writeMarkdownTap(&b, rep.forTap(name), "###")
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// writeMarkdownTap schreibt Zähler und Listen eines (gefilterten) Reports;
+// heading ist die Markdown Ebene der behind Tabellen ("##" bzw. "###" unter einem Tap).
+func writeMarkdownTap(b *strings.Builder, rep report, heading string) {
+	b.WriteString("| | Count |\n|---|---:|\n")
+	fmt.Fprintf(b, "| Formulae | %d |\n", rep.privateCount)
+	if rep.caskCount > 0 {
+		fmt.Fprintf(b, "| Casks | %d |\n", rep.caskCount)
+	}
+	fmt.Fprintf(b, "| Behind upstream | %d |\n", len(rep.behind))
+	fmt.Fprintf(b, "| Not found upstream | %d |\n", len(rep.notFound))
+	fmt.Fprintf(b, "| Unparsed (no version) | %d |\n", len(rep.unparsed))
+	if len(rep.lineFallback) > 0 {
+		fmt.Fprintf(b, "| Parsed by line fallback | %d |\n", len(rep.lineFallback))
+	}
+	if len(rep.ignored) > 0 {
+		fmt.Fprintf(b, "| Ignored (config) | %d |\n", len(rep.ignored))
+	}
+	if len(rep.notChecked) > 0 {
+		fmt.Fprintf(b, "| Not checked (run aborted) | %d |\n", len(rep.notChecked))
+	}
+	fmt.Fprintf(b, "| Errors | %d |\n\n", len(rep.errorsList))
+
+	for _, k := range []kind{kindFormula, kindCask} {
+		rows := behindOfKind(rep.behind, k)
+		if len(rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "%s %s (%d)\n\n", heading, behindTitles[k], len(rows))
+		b.WriteString("| Name | Upstream | Private | Source | Upstream version | File |\n")
+		b.WriteString("|---|---|---|---|---|---|\n")
+		for _, r := range rows {
+			fmt.Fprintf(b, "| `%s` | `%s` | %s | %s | %s | `%s` |\n",
+				mdCell(r.privateName), mdCell(r.upstream), mdCell(r.privateVer), r.privateSrc, mdCell(r.upstreamVer), mdCell(r.privatePath))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(rep.notFound) > 0 {
+		fmt.Fprintf(b, "<details>\n<summary>Not found upstream (%d)</summary>\n\n", len(rep.notFound))
+		for _, nf := range rep.notFound {
+			fmt.Fprintf(b, "- `%s` (%s, searched: `%s`)\n", nf.privateName, nf.kind, nf.upstream)
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+
+	if len(rep.unparsed) > 0 {
+		fmt.Fprintf(b, "<details>\n<summary>Unparsed, no version found (%d)</summary>\n\n", len(rep.unparsed))
+		for _, u := range rep.unparsed {
+			fmt.Fprintf(b, "- `%s` (%s): %s, `%s`\n", u.Name, u.Kind, mdCell(u.Reason), u.Path)
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+
+	if len(rep.lineFallback) > 0 {
+		fmt.Fprintf(b, "<details>\n<summary>Parsed by line fallback, please check (%d)</summary>\n\n", len(rep.lineFallback))
+		for _, u := range rep.lineFallback {
+			fmt.Fprintf(b, "- `%s`: %s, `%s`\n", u.Name, mdCell(u.Reason), u.Path)
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+
+	if len(rep.errorsList) > 0 {
+		fmt.Fprintf(b, "<details>\n<summary>Errors (%d)</summary>\n\n", len(rep.errorsList))
+		for _, le := range rep.errorsList {
+			fmt.Fprintf(b, "- `%s` -> `%s`: %s\n", le.privateName, le.upstream, mdCell(le.err.Error()))
+		}
+		b.WriteString("\n</details>\n")
+	}
+}
+
+// mdCell escaped Zeichen, die eine Markdown Tabellenzeile kaputt machen würden.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// behindOfKind filtert die behind Einträge auf eine Art (Formula/Cask).
+func behindOfKind(rows []behindRow, k kind) []behindRow {
+	var out []behindRow
+	for _, r := range rows {
+		if r.kind == k {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// summaryHTMLTemplate ist eine einzelne, selbständige Seite (CSS inline, keine externen Assets).
+var summaryHTMLTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Tap Version Audit</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+table { border-collapse: collapse; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #d0d7de; padding: .35rem .7rem; text-align: left; }
+th { background: #f6f8fa; }
+td.num { text-align: right; }
+code { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: .9em; }
+details { margin-bottom: 1rem; }
+summary { cursor: pointer; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>Tap Version Audit</h1>
+<p>Run: <time datetime="{{.Run}}">{{.Run}}</time></p>
+{{- if .Incomplete}}
+<p><strong>Incomplete:</strong> {{.Incomplete}}; {{.NotChecked}} entries not checked.</p>
+{{- end}}
+{{- if .Multi}}
+<table>
+<tr><th>Tap</th><th>Formulae</th><th>Casks</th><th>Behind</th><th>Not found</th><th>Unparsed</th><th>Errors</th></tr>
+{{- range .Taps}}
+<tr><td><code>{{.Name}}</code></td><td class="num">{{.Formulae}}</td><td class="num">{{.Casks}}</td><td class="num">{{.Behind}}</td><td class="num">{{len .NotFound}}</td><td class="num">{{len .Unparsed}}</td><td class="num">{{len .Errors}}</td></tr>
+{{- end}}
+</table>
+{{- if .Duplicates}}
+<h2>Duplicates across taps ({{len .Duplicates}})</h2>
+<ul>
+{{- range .Duplicates}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Taps}}
+<h2>Tap <code>{{.Name}}</code></h2>
+{{- template "tap" .}}
+{{- end}}
+{{- else}}
+{{- range .Taps}}{{template "tap" .}}{{end}}
+{{- end}}
+</body>
+</html>
+
+{{- define "tap"}}
+<table>
+<tr><th></th><th>Count</th></tr>
+<tr><td>Formulae</td><td class="num">{{.Formulae}}</td></tr>
+{{- if .Casks}}
+<tr><td>Casks</td><td class="num">{{.Casks}}</td></tr>
+{{- end}}
+<tr><td>Behind upstream</td><td class="num">{{.Behind}}</td></tr>
+<tr><td>Not found upstream</td><td class="num">{{len .NotFound}}</td></tr>
+<tr><td>Unparsed (no version)</td><td class="num">{{len .Unparsed}}</td></tr>
+{{- if .LineFallback}}
+<tr><td>Parsed by line fallback</td><td class="num">{{len .LineFallback}}</td></tr>
+{{- end}}
+{{- if .Ignored}}
+<tr><td>Ignored (config)</td><td class="num">{{.Ignored}}</td></tr>
+{{- end}}
+{{- if .NotChecked}}
+<tr><td>Not checked (run aborted)</td><td class="num">{{.NotChecked}}</td></tr>
+{{- end}}
+<tr><td>Errors</td><td class="num">{{len .Errors}}</td></tr>
+</table>
+{{- $nested := .Nested}}
+{{- range .Sections}}
+{{- if .Rows}}
+{{- if $nested}}
+<h3>{{.Title}} ({{len .Rows}})</h3>
+{{- else}}
+<h2>{{.Title}} ({{len .Rows}})</h2>
+{{- end}}
+<table>
+<tr><th>Name</th><th>Upstream</th><th>Private</th><th>Source</th><th>Upstream version</th><th>File</th></tr>
+{{- range .Rows}}
+<tr><td><code>{{.Name}}</code></td><td><code>{{.Upstream}}</code></td><td>{{.PrivateVer}}</td><td>{{.Source}}</td><td>{{.UpstreamVer}}</td><td><code>{{.Path}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- if .NotFound}}
+<details>
+<summary>Not found upstream ({{len .NotFound}})</summary>
+<ul>
+{{- range .NotFound}}
+<li><code>{{.Name}}</code> ({{.Kind}}, searched: <code>{{.Upstream}}</code>)</li>
+{{- end}}
+</ul>
+</details>
+{{- end}}
+{{- if .Unparsed}}
+<details>
+<summary>Unparsed, no version found ({{len .Unparsed}})</summary>
+<ul>
+{{- range .Unparsed}}
+<li><code>{{.Name}}</code> ({{.Kind}}): {{.Message}}, <code>{{.Path}}</code></li>
+{{- end}}
+</ul>
+</details>
+{{- end}}
+{{- if .LineFallback}}
+<details>
+<summary>Parsed by line fallback, please check ({{len .LineFallback}})</summary>
+<ul>
+{{- range .LineFallback}}
+<li><code>{{.Name}}</code>: {{.Message}}, <code>{{.Path}}</code></li>
+{{- end}}
+</ul>
+</details>
+{{- end}}
+{{- if .Errors}}
+<details>
+<summary>Errors ({{len .Errors}})</summary>
+<ul>
+{{- range .Errors}}
+<li><code>{{.Name}}</code> -&gt; <code>{{.Upstream}}</code>: {{.Message}}</li>
+{{- end}}
+</ul>
+</details>
+{{- end}}
+{{- end}}`))
+
+// summaryRow / summarySection sind die exported Sichten für das HTML Template
+// (html/template kann nicht auf unexported Felder von behindRow zugreifen).
+type summaryRow struct {
+	Name, Upstream, PrivateVer, Source, UpstreamVer, Path, Kind, Message string
+}
+
+type summarySection struct {
+	Title string
+	Rows  []summaryRow
+}
+
+// summaryTap sind Zähler und Listen eines Taps (bzw. des ganzen Reports bei einem Tap).
+// Nested: Tap-Abschnitt unter einer eigenen <h2>, die behind Tabellen rutschen auf <h3>.
+type summaryTap struct {
+	Name                    string
+	Nested                  bool
+	Formulae, Casks, Behind int
+	Ignored, NotChecked     int
+	Sections                []summarySection
+	NotFound                []summaryRow
+	Unparsed                []summaryRow
+	LineFallback            []summaryRow
+	Errors                  []summaryRow
+}
+
+// renderHTMLSummary baut die HTML Variante; html/template übernimmt das Escaping.
+func renderHTMLSummary(rep report) ([]byte, error) {
+	data := struct {
+		Run        string
+		Incomplete string
+		NotChecked int
+		Multi      bool
+		Duplicates []string
+		Taps       []summaryTap
+	}{
+		Run:        rep.generatedAt.Format(time.RFC3339),
+		Incomplete: rep.incomplete,
+		NotChecked: len(rep.notChecked),
+		Multi:      len(rep.taps) > 1,
+	}
+
+	if data.Multi {
+		for _, d := range rep.duplicates {
+			data.Duplicates = append(data.Duplicates, d.String())
+		}
+		for _, name := range rep.taps {
+			t := htmlSummaryTap(rep.forTap(name))
+			t.Name, t.Nested = name, true
+			data.Taps = append(data.Taps, t)
+		}
+	} else {
+		data.Taps = []summaryTap{htmlSummaryTap(rep)}
+	}
+
+	var buf bytes.Buffer
+	if err := summaryHTMLTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// htmlSummaryTap übersetzt einen (gefilterten) Report in die Template-Sicht.
+func htmlSummaryTap(rep report) summaryTap {
+	data := summaryTap{
+		Formulae:   rep.privateCount,
+		Casks:      rep.caskCount,
+		Behind:     len(rep.behind),
+		Ignored:    len(rep.ignored),
+		NotChecked: len(rep.notChecked),
+	}
+
+	for _, k := range []kind{kindFormula, kindCask} {
+		sec := summarySection{Title: behindTitles[k]}
+		for _, r := range behindOfKind(rep.behind, k) {
+			sec.Rows = append(sec.Rows, summaryRow{
+				Name: r.privateName, Upstream: r.upstream,
+				PrivateVer: r.privateVer, Source: string(r.privateSrc), UpstreamVer: r.upstreamVer, Path: r.privatePath,
+			})
+		}
+		data.Sections = append(data.Sections, sec)
+	}
+	for _, nf := range rep.notFound {
+		data.NotFound = append(data.NotFound, summaryRow{Name: nf.privateName, Upstream: nf.upstream, Kind: nf.kind.String()})
+	}
+	for _, u := range rep.unparsed {
+		data.Unparsed = append(data.Unparsed, summaryRow{Name: u.Name, Kind: u.Kind.String(), Message: u.Reason, Path: u.Path})
+	}
+	for _, u := range rep.lineFallback {
+		data.LineFallback = append(data.LineFallback, summaryRow{Name: u.Name, Kind: u.Kind.String(), Message: u.Reason, Path: u.Path})
+	}
+	for _, le := range rep.errorsList {
+		data.Errors = append(data.Errors, summaryRow{Name: le.privateName, Upstream: le.upstream, Message: le.err.Error()})
+	}
+	return data
+}
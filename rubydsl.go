@@ -0,0 +1,1023 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---- Mini-Parser für die Homebrew Formula/Cask DSL ----
+//
+// Kein vollständiger Ruby-Parser, sondern gerade genug, um Formula-Files
+// block-bewusst zu lesen:
+//
+//	class Foo < Formula          -> Klassen-Deklaration
+//	  url "..." / sha256 "..."   -> stable (top-level oder in `stable do`)
+//	  head do ... end            -> eigener Block, zählt NICHT zu stable
+//	  resource "x" do ... end    -> eigener Block
+//	  on_macos do ... end        -> eigener Block
+//
+// Ablauf:
+// 1) Tokenizer (rbLex): Strings, Heredocs, %w[] Literale, Regex, Kommentare,
+//    __END__ usw. werden korrekt übersprungen, damit ein "do"/"end" in einem
+//    String oder Heredoc die Block-Struktur nicht kaputt macht.
+// 2) Statements + Blöcke (parseRuby): Tokens werden zu Statements gruppiert,
+//    do/class/def/if/... öffnen, end schliesst einen Block.
+// 3) Zugriff (formulaDoc, rbStanza): url/version/sha256/revision/depends_on
+//    aus dem richtigen Block, inkl. Byte-Offsets für spätere Rewrites.
+
+type rbTokKind int
+
+const (
+	rbIdent   rbTokKind = iota // foo, Foo, @foo, intel?
+	rbLabel                    // tag:  (Keyword-Argument, ohne den Doppelpunkt)
+	rbSymbol                   // :git
+	rbString                   // "..." / '...' / %w[...] / Heredoc-Marker
+	rbNumber                   // 1, 1.5
+	rbRegex                    // /.../
+	rbOp                       // , ( ) => :: . usw.
+	rbNewline                  // Zeilenende (auch ;)
+)
+
+// rbToken ist ein Token mit seiner Position im Source.
+// src[start:end] ist immer der Original-Text (inkl. Quotes bei Strings).
+type rbToken struct {
+	kind  rbTokKind
+	text  string
+	start int
+	end   int
+	line  int
+}
+
+// rbLexer hält den Zustand beim Tokenisieren.
+type rbLexer struct {
+	src      string
+	pos      int
+	line     int
+	toks     []rbToken
+	heredocs []rbHeredoc // Heredocs, deren Body nach dem aktuellen Zeilenende beginnt
+}
+
+type rbHeredoc struct {
+	id       string
+	indented bool // <<~ / <<- : Terminator darf eingerückt sein
+}
+
+// rbLex zerlegt src in Tokens. Alles nach einer __END__ Zeile (DATA für Patches) wird ignoriert.
+func rbLex(src string) ([]rbToken, error) {
+	lx := &rbLexer{src: src, line: 1}
+	if err := lx.run(); err != nil {
+		return nil, err
+	}
+	return lx.toks, nil
+}
+
+func (lx *rbLexer) emit(k rbTokKind, start int) {
+	lx.toks = append(lx.toks, rbToken{kind: k, text: lx.src[start:lx.pos], start: start, end: lx.pos, line: lx.line})
+}
+
+func (lx *rbLexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", lx.line, fmt.Sprintf(format, args...))
+}
+
+// prev liefert das letzte Token (oder nil am Anfang).
+func (lx *rbLexer) prev() *rbToken {
+	if len(lx.toks) == 0 {
+		return nil
+	}
+	return &lx.toks[len(lx.toks)-1]
+}
+
+func (lx *rbLexer) run() error {
+	src := lx.src
+	for lx.pos < len(src) {
+		c := src[lx.pos]
+
+		// Zeilenanfang: __END__ und =begin/=end Kommentare
+		if lx.pos == 0 || src[lx.pos-1] == '\n' {
+			if lineIs(src[lx.pos:], "__END__") {
+				lx.pos = len(src)
+				break
+			}
+			if strings.HasPrefix(src[lx.pos:], "=begin") {
+				if err := lx.skipBlockComment(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		switch {
+		case c == '\n':
+			start := lx.pos
+			lx.pos++
+			lx.emit(rbNewline, start)
+			lx.line++
+			if err := lx.readHeredocBodies(); err != nil {
+				return err
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			lx.pos++
+		case c == '\\' && lx.pos+1 < len(src) && src[lx.pos+1] == '\n':
+			// explizite Zeilenfortsetzung: weder Token noch Newline
+			lx.pos += 2
+			lx.line++
+		case c == '#':
+			for lx.pos < len(src) && src[lx.pos] != '\n' {
+				lx.pos++
+			}
+		case c == ';':
+			start := lx.pos
+			lx.pos++
+			lx.emit(rbNewline, start)
+		case c == '"' || c == '\'' || c == '`':
+			start := lx.pos
+			end, err := lx.scanQuoted(lx.pos, c, c, false)
+			if err != nil {
+				return err
+			}
+			lx.pos = end
+			lx.emit(rbString, start)
+		case c == '<' && lx.heredocStart():
+			// lx.heredocStart hat den Marker bereits konsumiert und gemerkt
+		case c == '%' && lx.percentLiteral():
+			// %w[...] etc. wurde als String emittiert
+		case c == '/' && lx.regexAllowed():
+			start := lx.pos
+			end, err := lx.scanQuoted(lx.pos, '/', '/', false)
+			if err != nil {
+				return err
+			}
+			lx.pos = end
+			for lx.pos < len(src) && isIdentChar(src[lx.pos]) { // Flags: /x/i
+				lx.pos++
+			}
+			lx.emit(rbRegex, start)
+		case c == ':' && lx.pos+1 < len(src) && src[lx.pos+1] == ':':
+			start := lx.pos
+			lx.pos += 2
+			lx.emit(rbOp, start)
+		case c == ':' && lx.pos+1 < len(src) && (isIdentStart(src[lx.pos+1]) || src[lx.pos+1] == '"'):
+			start := lx.pos
+			if src[lx.pos+1] == '"' {
+				end, err := lx.scanQuoted(lx.pos+1, '"', '"', false)
+				if err != nil {
+					return err
+				}
+				lx.pos = end
+			} else {
+				lx.pos++
+				lx.scanIdent()
+			}
+			lx.emit(rbSymbol, start)
+		case isDigit(c):
+			start := lx.pos
+			for lx.pos < len(src) && (isDigit(src[lx.pos]) || src[lx.pos] == '_' ||
+				(src[lx.pos] == '.' && lx.pos+1 < len(src) && isDigit(src[lx.pos+1]))) {
+				lx.pos++
+			}
+			lx.emit(rbNumber, start)
+		case isIdentStart(c) || c == '@' || c == '$':
+			start := lx.pos
+			if c == '@' || c == '$' {
+				lx.pos++
+				if lx.pos < len(src) && src[lx.pos] == '@' {
+					lx.pos++
+				}
+			}
+			lx.scanIdent()
+			// Label: tag: "..." (aber nicht Foo::Bar)
+			if lx.pos < len(src) && src[lx.pos] == ':' &&
+				(lx.pos+1 >= len(src) || src[lx.pos+1] != ':') {
+				lx.toks = append(lx.toks, rbToken{kind: rbLabel, text: src[start:lx.pos], start: start, end: lx.pos + 1, line: lx.line})
+				lx.pos++
+				continue
+			}
+			lx.emit(rbIdent, start)
+		default:
+			start := lx.pos
+			lx.pos += opLen(src[lx.pos:])
+			lx.emit(rbOp, start)
+		}
+	}
+
+	if len(lx.heredocs) > 0 {
+		return lx.errorf("unterminated heredoc %s", lx.heredocs[0].id)
+	}
+	return nil
+}
+
+// scanIdent liest einen Identifier ab lx.pos (inkl. trailing ? oder !, aber nicht != / ?=).
+func (lx *rbLexer) scanIdent() {
+	src := lx.src
+	for lx.pos < len(src) && isIdentChar(src[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos < len(src) && (src[lx.pos] == '?' || src[lx.pos] == '!') &&
+		(lx.pos+1 >= len(src) || src[lx.pos+1] != '=') {
+		lx.pos++
+	}
+}
+
+// scanQuoted liest ein String-/Regex-Literal ab i (src[i] ist der öffnende Delimiter)
+// und gibt den Index nach dem schliessenden Delimiter zurück.
+// Bei open != close (z.B. %w[...]) wird verschachtelt gezählt.
+// Interpolation #{...} wird bei "-Strings (und interp=true) inkl. verschachtelter Strings übersprungen.
+func (lx *rbLexer) scanQuoted(i int, open, close byte, interp bool) (int, error) {
+	src := lx.src
+	interp = interp || open == '"' || open == '`' || open == '/'
+	depth := 1
+	for j := i + 1; j < len(src); j++ {
+		switch c := src[j]; {
+		case c == '\\':
+			j++
+		case c == '\n':
+			lx.line++
+		case interp && c == '#' && j+1 < len(src) && src[j+1] == '{':
+			end, err := lx.scanInterpolation(j + 1)
+			if err != nil {
+				return 0, err
+			}
+			j = end - 1
+		case c == close && open != close:
+			depth--
+			if depth == 0 {
+				return j + 1, nil
+			}
+		case c == open && open != close:
+			depth++
+		case c == close:
+			return j + 1, nil
+		}
+	}
+	return 0, lx.errorf("unterminated literal starting with %q", open)
+}
+
+// scanInterpolation überspringt #{ ... } ab der öffnenden Klammer bei i.
+func (lx *rbLexer) scanInterpolation(i int) (int, error) {
+	src := lx.src
+	depth := 0
+	for j := i; j < len(src); j++ {
+		switch c := src[j]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1, nil
+			}
+		case '"', '\'':
+			end, err := lx.scanQuoted(j, c, c, false)
+			if err != nil {
+				return 0, err
+			}
+			j = end - 1
+		case '\n':
+			lx.line++
+		}
+	}
+	return 0, lx.errorf("unterminated interpolation")
+}
+
+// heredocStart erkennt <<~ID, <<-ID, <<ID, <<~'ID', <<~"ID".
+// `args << "--foo"` (mit Leerzeichen) ist der Shift-Operator, kein Heredoc.
+func (lx *rbLexer) heredocStart() bool {
+	src := lx.src
+	if !strings.HasPrefix(src[lx.pos:], "<<") {
+		return false
+	}
+	j := lx.pos + 2
+	indented := false
+	if j < len(src) && (src[j] == '~' || src[j] == '-') {
+		indented = true
+		j++
+	}
+	if j >= len(src) {
+		return false
+	}
+
+	var id string
+	end := j
+	switch {
+	case src[j] == '\'' || src[j] == '"':
+		k := strings.IndexByte(src[j+1:], src[j])
+		if k <= 0 {
+			return false
+		}
+		id = src[j+1 : j+1+k]
+		end = j + 1 + k + 1
+	case src[j] >= 'A' && src[j] <= 'Z' || src[j] == '_':
+		for end < len(src) && (isIdentChar(src[end])) {
+			end++
+		}
+		id = src[j:end]
+	default:
+		return false
+	}
+
+	start := lx.pos
+	lx.pos = end
+	lx.emit(rbString, start)
+	lx.heredocs = append(lx.heredocs, rbHeredoc{id: id, indented: indented})
+	return true
+}
+
+// readHeredocBodies überspringt die Bodies aller offenen Heredocs (direkt nach einem Newline).
+func (lx *rbLexer) readHeredocBodies() error {
+	src := lx.src
+	for len(lx.heredocs) > 0 {
+		h := lx.heredocs[0]
+		for {
+			if lx.pos >= len(src) {
+				return lx.errorf("unterminated heredoc %s", h.id)
+			}
+			nl := strings.IndexByte(src[lx.pos:], '\n')
+			lineEnd := len(src)
+			if nl != -1 {
+				lineEnd = lx.pos + nl
+			}
+			line := src[lx.pos:lineEnd]
+			lx.pos = lineEnd
+			if nl != -1 {
+				lx.pos++
+				lx.line++
+			}
+			if h.indented {
+				line = strings.TrimSpace(line)
+			}
+			if strings.TrimRight(line, "\r") == h.id {
+				break
+			}
+		}
+		lx.heredocs = lx.heredocs[1:]
+	}
+	return nil
+}
+
+// percentLiteral erkennt %w[...], %i[...], %q(...), %Q{...}, %r{...}, %(...).
+// Ein % nach einem Wert ohne Typ-Buchstaben (z.B. "%s" % x) bleibt ein Operator.
+func (lx *rbLexer) percentLiteral() bool {
+	src := lx.src
+	j := lx.pos + 1
+	interp := true
+	if j < len(src) && strings.IndexByte("wWiIqQrsx", src[j]) != -1 {
+		interp = src[j] != 'w' && src[j] != 'i' && src[j] != 'q' && src[j] != 's'
+		j++
+	} else if p := lx.prev(); p != nil && isValueToken(*p) {
+		return false
+	}
+	if j >= len(src) {
+		return false
+	}
+	open := src[j]
+	closeBy := map[byte]byte{'(': ')', '[': ']', '{': '}', '<': '>', '|': '|', '!': '!', '/': '/'}
+	cl, ok := closeBy[open]
+	if !ok {
+		return false
+	}
+	start, line := lx.pos, lx.line
+	end, err := lx.scanQuoted(j, open, cl, interp)
+	if err != nil {
+		lx.line = line
+		return false
+	}
+	lx.pos = end
+	lx.emit(rbString, start)
+	return true
+}
+
+// regexAllowed entscheidet, ob ein / ein Regex-Literal oder die Division ist.
+// prefix/"bin" (Pathname) ist in Formulae sehr häufig und muss Division bleiben.
+func (lx *rbLexer) regexAllowed() bool {
+	p := lx.prev()
+	if p == nil || p.kind == rbNewline || p.kind == rbLabel {
+		return true
+	}
+	if p.kind == rbOp {
+		return p.text != ")" && p.text != "]" && p.text != "}"
+	}
+	if p.kind == rbIdent {
+		if isKeyword(p.text) {
+			return true
+		}
+		// "inreplace x, /re/" wäre oben (Komma); hier: "match /re/" vs "a / b" vs "prefix/x"
+		src := lx.src
+		spaceBefore := lx.pos > 0 && src[lx.pos-1] == ' '
+		spaceAfter := lx.pos+1 < len(src) && src[lx.pos+1] == ' '
+		return spaceBefore && !spaceAfter
+	}
+	return false
+}
+
+func (lx *rbLexer) skipBlockComment() error {
+	src := lx.src
+	for lx.pos < len(src) {
+		nl := strings.IndexByte(src[lx.pos:], '\n')
+		if nl == -1 {
+			return lx.errorf("unterminated =begin comment")
+		}
+		line := src[lx.pos : lx.pos+nl]
+		lx.pos += nl + 1
+		lx.line++
+		if strings.HasPrefix(line, "=end") {
+			return nil
+		}
+	}
+	return lx.errorf("unterminated =begin comment")
+}
+
+// opLen liefert die Länge des (längsten) Operators am Anfang von s.
+func opLen(s string) int {
+	for _, op := range []string{"<=>", "===", "...", "**=", "||=", "&&=", "=>", "==", "!=", "=~", "!~", "<=", ">=",
+		"&&", "||", "<<", ">>", "**", "..", "->", "&.", "+=", "-=", "*=", "/="} {
+		if strings.HasPrefix(s, op) {
+			return len(op)
+		}
+	}
+	return 1
+}
+
+func lineIs(s, want string) bool {
+	if !strings.HasPrefix(s, want) {
+		return false
+	}
+	rest := s[len(want):]
+	return rest == "" || rest[0] == '\n' || rest[0] == '\r'
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+func isIdentChar(c byte) bool { return isIdentStart(c) || isDigit(c) }
+
+// isValueToken: Token, nach dem ein Operator (und kein neues Literal) erwartet wird.
+func isValueToken(t rbToken) bool {
+	switch t.kind {
+	case rbIdent:
+		return !isKeyword(t.text)
+	case rbString, rbNumber, rbSymbol, rbRegex:
+		return true
+	case rbOp:
+		return t.text == ")" || t.text == "]" || t.text == "}"
+	}
+	return false
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "if", "unless", "while", "until", "when", "and", "or", "not", "return", "then", "else", "elsif",
+		"case", "in", "do", "begin", "end", "class", "module", "def", "yield":
+		return true
+	}
+	return false
+}
+
+// ---- Statements und Blöcke ----
+
+// rbNode ist ein Statement. Öffnet es einen Block (do ... end, class ... end),
+// stehen die enthaltenen Statements in children.
+//
+// start/end sind Byte-Offsets im Source: bei einfachen Statements das Statement
+// selbst, bei Blöcken bis einschliesslich des schliessenden `end`.
+type rbNode struct {
+	toks     []rbToken
+	start    int
+	end      int
+	isBlock  bool
+	children []*rbNode
+
+	open int // interne Anzahl noch offener Block-Level (für Statements mit mehreren Openern)
+}
+
+// name ist der erste Identifier des Statements (z.B. "url", "resource", "class").
+func (n *rbNode) name() string {
+	if len(n.toks) == 0 || n.toks[0].kind != rbIdent {
+		return ""
+	}
+	return n.toks[0].text
+}
+
+// parseRuby baut aus src einen Baum von Statements. Der Root-Knoten ist ein
+// künstlicher Block, der das ganze File enthält.
+func parseRuby(src string) (*rbNode, error) {
+	toks, err := rbLex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &rbNode{isBlock: true, end: len(src), open: 1}
+	stack := []*rbNode{root}
+
+	var stmt []rbToken
+	parenDepth := 0
+
+	flush := func() error {
+		if len(stmt) == 0 {
+			return nil
+		}
+		toks := stmt
+		stmt = nil
+
+		opens, ends := blockDelta(toks)
+		top := stack[len(stack)-1]
+
+		// reines "end" (evtl. mit Anhängseln wie end.freeze): Blöcke schliessen
+		if toks[0].kind == rbIdent && toks[0].text == "end" && opens == 0 {
+			for i := 0; i < ends; i++ {
+				if len(stack) == 1 {
+					return fmt.Errorf("line %d: unexpected end", toks[0].line)
+				}
+				top = stack[len(stack)-1]
+				top.open--
+				if top.open == 0 {
+					top.end = toks[len(toks)-1].end
+					stack = stack[:len(stack)-1]
+				}
+			}
+			return nil
+		}
+
+		n := &rbNode{toks: toks, start: toks[0].start, end: toks[len(toks)-1].end}
+		top.children = append(top.children, n)
+		if delta := opens - ends; delta > 0 {
+			n.isBlock = true
+			n.open = delta
+			stack = append(stack, n)
+		} else if delta < 0 {
+			return fmt.Errorf("line %d: unexpected end", toks[0].line)
+		}
+		return nil
+	}
+
+	for i, t := range toks {
+		switch {
+		case t.kind == rbOp && (t.text == "(" || t.text == "[" || t.text == "{"):
+			parenDepth++
+		case t.kind == rbOp && (t.text == ")" || t.text == "]" || t.text == "}"):
+			if parenDepth > 0 {
+				parenDepth--
+			}
+		case t.kind == rbNewline:
+			if parenDepth > 0 || continuesStatement(stmt, toks[i+1:]) {
+				stmt = append(stmt, t) // Newline bleibt für blockDelta sichtbar
+				continue
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		stmt = append(stmt, t)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(stack) > 1 {
+		n := stack[len(stack)-1]
+		return nil, fmt.Errorf("line %d: block %q is never closed", n.toks[0].line, n.name())
+	}
+	return root, nil
+}
+
+// continuesStatement: geht das Statement nach dem Newline weiter?
+// - letzte Zeile endet mit , oder einem Operator (z.B. url "...",\n tag: "...")
+// - nächste Zeile beginnt mit .methode (Method Chain)
+func continuesStatement(stmt, rest []rbToken) bool {
+	var last *rbToken
+	for i := len(stmt) - 1; i >= 0; i-- {
+		if stmt[i].kind != rbNewline {
+			last = &stmt[i]
+			break
+		}
+	}
+	if last == nil {
+		return false
+	}
+	if last.kind == rbLabel {
+		return true
+	}
+	// ")" "]" "}" schliessen einen Ausdruck, "|" schliesst Block-Parameter (do |f|)
+	if last.kind == rbOp && last.text != ")" && last.text != "]" && last.text != "}" && last.text != "|" {
+		return true
+	}
+	for _, t := range rest {
+		if t.kind == rbNewline {
+			continue
+		}
+		return t.kind == rbOp && (t.text == "." || t.text == "&.")
+	}
+	return false
+}
+
+// blockDelta zählt Block-Opener und `end` in einem Statement.
+//
+// Opener: do, class, module, def, begin, case, for sowie if/unless/while/until am
+// Anfang eines Ausdrucks (nicht als Modifier wie `system "x" if OS.mac?`).
+// Ausnahmen:
+// - das optionale do von `while x do`, `until x do`, `for a in b do` gehört zur Schleife (ein end)
+// - endless defs (`def self.foo = 1`, `def foo(x) = x`) haben kein end
+func blockDelta(toks []rbToken) (opens, ends int) {
+	loop := false // offene while/until/for Bedingung bis zum Zeilenende: ein do gehört dazu
+	for i, t := range toks {
+		if t.kind == rbNewline {
+			loop = false
+			continue
+		}
+		if t.kind != rbIdent {
+			continue
+		}
+		// foo.class / foo.end sind Methodenaufrufe, keine Keywords
+		if i > 0 && toks[i-1].kind == rbOp && (toks[i-1].text == "." || toks[i-1].text == "&.") {
+			continue
+		}
+		switch t.text {
+		case "do":
+			if loop {
+				loop = false
+				continue
+			}
+			opens++
+		case "class", "module", "begin", "case":
+			opens++
+		case "def":
+			if !isEndlessDef(toks, i) {
+				opens++
+			}
+		case "for":
+			opens++
+			loop = true
+		case "if", "unless", "while", "until":
+			if i == 0 || !isModifierPrefix(toks[i-1]) {
+				opens++
+				loop = t.text == "while" || t.text == "until"
+			}
+		case "end":
+			ends++
+		}
+	}
+	return opens, ends
+}
+
+// isEndlessDef: ist toks[i] (def) eine endless Methode (`def foo = 1`, `def self.foo(x) = x`)?
+// Ein Setter `def foo=(v)` hat das = direkt am Namen und ist eine normale Methode.
+func isEndlessDef(toks []rbToken, i int) bool {
+	j := i + 1
+	if j+1 < len(toks) && toks[j].text == "self" && toks[j+1].text == "." {
+		j += 2
+	}
+	j++ // Methodenname (Identifier oder Operator wie ==)
+	if j < len(toks) && toks[j].kind == rbOp && toks[j].text == "(" {
+		depth := 0
+		for ; j < len(toks); j++ {
+			if toks[j].kind != rbOp {
+				continue
+			}
+			if toks[j].text == "(" {
+				depth++
+			} else if toks[j].text == ")" {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		j++
+		return j < len(toks) && toks[j].kind == rbOp && toks[j].text == "="
+	}
+	return j < len(toks) && toks[j].kind == rbOp && toks[j].text == "=" && toks[j].start > toks[j-1].end
+}
+
+// isModifierPrefix: steht vor if/unless/... ein Ausdruck, ist es ein Modifier
+// (`system "x" if OS.mac?`, `return unless x`, `end if y`) und öffnet keinen Block.
+func isModifierPrefix(prev rbToken) bool {
+	if prev.kind == rbIdent {
+		switch prev.text {
+		case "return", "next", "break", "redo", "retry", "end":
+			return true
+		}
+	}
+	return isValueToken(prev)
+}
+
+// ---- Stanzas (Methodenaufrufe wie url "...", tag: "...") ----
+
+// rbArg ist ein Argument eines Stanzas: positional (key == "") oder Keyword (tag: "...", "x" => :build).
+type rbArg struct {
+	key  string
+	toks []rbToken
+}
+
+// str liefert den Wert, falls das Argument ein einzelnes String-Literal ohne Interpolation ist.
+func (a rbArg) str() (string, bool) {
+	if len(a.toks) != 1 || a.toks[0].kind != rbString {
+		return "", false
+	}
+	return rbUnquote(a.toks[0].text)
+}
+
+// sym liefert den Namen, falls das Argument ein Symbol ist (:git -> "git").
+func (a rbArg) sym() (string, bool) {
+	if len(a.toks) != 1 || a.toks[0].kind != rbSymbol {
+		return "", false
+	}
+	return strings.Trim(a.toks[0].text[1:], `"`), true
+}
+
+// rbStanza ist ein Statement in Aufruf-Form: name arg, key: val, ...
+type rbStanza struct {
+	node *rbNode
+	name string
+	args []rbArg
+}
+
+// first liefert das erste positionale Argument (oder nil).
+func (s *rbStanza) first() *rbArg {
+	for i := range s.args {
+		if s.args[i].key == "" {
+			return &s.args[i]
+		}
+	}
+	return nil
+}
+
+// kw liefert das Keyword-Argument key (oder nil).
+func (s *rbStanza) kw(key string) *rbArg {
+	for i := range s.args {
+		if s.args[i].key == key {
+			return &s.args[i]
+		}
+	}
+	return nil
+}
+
+// stringValue ist der String-Wert des ersten Arguments (url "x" -> "x").
+func (s *rbStanza) stringValue() (string, bool) {
+	if a := s.first(); a != nil {
+		return a.str()
+	}
+	return "", false
+}
+
+// asStanza interpretiert ein Statement als Aufruf. Blöcke (do ... end) zählen mit,
+// die Argumente gehen dann bis vor das `do`.
+func asStanza(n *rbNode) *rbStanza {
+	name := n.name()
+	if name == "" {
+		return nil
+	}
+	toks := n.toks[1:]
+	if n.isBlock && len(toks) > 0 {
+		for i, t := range toks {
+			if t.kind == rbIdent && t.text == "do" {
+				toks = toks[:i]
+				break
+			}
+		}
+	}
+	// url("...") -> Klammern um die komplette Argumentliste entfernen
+	if len(toks) >= 2 && toks[0].kind == rbOp && toks[0].text == "(" && toks[0].start == n.toks[0].end &&
+		toks[len(toks)-1].kind == rbOp && toks[len(toks)-1].text == ")" {
+		toks = toks[1 : len(toks)-1]
+	}
+
+	st := &rbStanza{node: n, name: name}
+	depth := 0
+	var cur []rbToken
+	push := func() {
+		cur = trimNewlines(cur)
+		if len(cur) == 0 {
+			return
+		}
+		a := rbArg{toks: cur}
+		if cur[0].kind == rbLabel {
+			a = rbArg{key: cur[0].text, toks: trimNewlines(cur[1:])}
+		} else {
+			for i, t := range cur {
+				if t.kind == rbOp && t.text == "=>" && i > 0 {
+					a = rbArg{key: hashKey(cur[:i]), toks: trimNewlines(cur[i+1:])}
+					break
+				}
+			}
+		}
+		st.args = append(st.args, a)
+		cur = nil
+	}
+	for _, t := range toks {
+		if t.kind == rbOp {
+			switch t.text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			case ",":
+				if depth == 0 {
+					push()
+					continue
+				}
+			}
+		}
+		cur = append(cur, t)
+	}
+	push()
+	return st
+}
+
+// hashKey macht aus den Tokens vor "=>" einen Key: "cmake" -> cmake, :build -> build.
+// Alles andere (Ausdrücke) wird als Token-Text zusammengesetzt.
+func hashKey(toks []rbToken) string {
+	a := rbArg{toks: trimNewlines(toks)}
+	if v, ok := a.str(); ok {
+		return v
+	}
+	if v, ok := a.sym(); ok {
+		return v
+	}
+	parts := make([]string, 0, len(a.toks))
+	for _, t := range a.toks {
+		parts = append(parts, t.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func trimNewlines(toks []rbToken) []rbToken {
+	for len(toks) > 0 && toks[0].kind == rbNewline {
+		toks = toks[1:]
+	}
+	for len(toks) > 0 && toks[len(toks)-1].kind == rbNewline {
+		toks = toks[:len(toks)-1]
+	}
+	return toks
+}
+
+// rbUnquote macht aus einem String-Token den Wert. Strings mit Interpolation (#{...})
+// oder %w[] Listen haben keinen statischen Wert -> ok=false.
+func rbUnquote(s string) (string, bool) {
+	if len(s) < 2 {
+		return "", false
+	}
+	switch s[0] {
+	case '\'':
+		r := strings.NewReplacer(`\\`, `\`, `\'`, `'`)
+		return r.Replace(s[1 : len(s)-1]), true
+	case '"':
+		if strings.Contains(s, "#{") {
+			return "", false
+		}
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			// Ruby erlaubt Escapes, die Go nicht kennt (z.B. \e) -> roh zurückgeben
+			return s[1 : len(s)-1], true
+		}
+		return v, true
+	}
+	return "", false
+}
+
+// ---- Formula / Cask Dokumente ----
+
+// formulaDoc ist ein geparstes Formula-File.
+type formulaDoc struct {
+	src       string
+	root      *rbNode
+	class     *rbNode // class Foo < Formula ... end
+	className rbToken // Token des Klassennamens (für Rewrites)
+	parent    string  // z.B. "Formula"
+}
+
+// parseFormula parst ein Formula-File und sucht die Klassen-Deklaration.
+func parseFormula(src string) (*formulaDoc, error) {
+	root, err := parseRuby(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range root.children {
+		if n.name() != "class" || !n.isBlock || len(n.toks) < 2 || n.toks[1].kind != rbIdent {
+			continue
+		}
+		d := &formulaDoc{src: src, root: root, class: n, className: n.toks[1]}
+		if len(n.toks) >= 4 && n.toks[2].text == "<" {
+			d.parent = n.toks[3].text
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("no class declaration found")
+}
+
+// stable liefert die Statements, die zur stable Spec gehören:
+// - gibt es einen `stable do` Block: dessen Inhalt
+// - sonst: die direkten Statements im class Body (ohne Inhalte von head/resource/on_* Blöcken)
+func (d *formulaDoc) stable() []*rbNode {
+	if b := findBlock(d.class.children, "stable"); b != nil {
+		return b.children
+	}
+	return d.class.children
+}
+
+// head liefert die Statements der head Spec. `head "url"` als Einzeiler ist selbst das url Statement.
+func (d *formulaDoc) head() []*rbNode {
+	for _, n := range d.class.children {
+		if n.name() != "head" {
+			continue
+		}
+		if n.isBlock {
+			return n.children
+		}
+		return []*rbNode{n}
+	}
+	return nil
+}
+
+// rbResource ist ein resource "name" do ... end Block.
+type rbResource struct {
+	name  string
+	nodes []*rbNode
+}
+
+// resources liefert alle resource Blöcke im class Body und im stable Block.
+func (d *formulaDoc) resources() []rbResource {
+	var out []rbResource
+	scopes := [][]*rbNode{d.class.children}
+	if b := findBlock(d.class.children, "stable"); b != nil {
+		scopes = append(scopes, b.children)
+	}
+	for _, scope := range scopes {
+		for _, n := range scope {
+			if n.name() != "resource" || !n.isBlock {
+				continue
+			}
+			name, _ := asStanza(n).stringValue()
+			out = append(out, rbResource{name: name, nodes: n.children})
+		}
+	}
+	return out
+}
+
+// dependsOn liefert die Namen aller depends_on im class Body (ohne on_* Blöcke).
+// depends_on "cmake" => :build -> "cmake"; depends_on :macos -> "macos"; depends_on macos: :ventura -> "macos"
+func (d *formulaDoc) dependsOn() []string {
+	var out []string
+	for _, n := range d.class.children {
+		if n.name() != "depends_on" {
+			continue
+		}
+		st := asStanza(n)
+		if len(st.args) == 0 {
+			continue
+		}
+		a := st.args[0]
+		if a.key != "" {
+			out = append(out, a.key)
+			continue
+		}
+		if v, ok := a.str(); ok {
+			out = append(out, v)
+		} else if v, ok := a.sym(); ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// findStanza sucht das erste Statement `name ...` direkt in scope (nicht in verschachtelten Blöcken).
+func findStanza(scope []*rbNode, name string) *rbStanza {
+	for _, n := range scope {
+		if n.name() == name && !n.isBlock {
+			return asStanza(n)
+		}
+	}
+	return nil
+}
+
+// findStanzas liefert alle Stanzas name direkt in scope (z.B. mehrere mirror Zeilen).
+func findStanzas(scope []*rbNode, name string) []*rbStanza {
+	var out []*rbStanza
+	for _, n := range scope {
+		if n.name() == name && !n.isBlock {
+			out = append(out, asStanza(n))
+		}
+	}
+	return out
+}
+
+// findBlock sucht den ersten Block `name do ... end` direkt in scope.
+func findBlock(scope []*rbNode, name string) *rbNode {
+	for _, n := range scope {
+		if n.name() == name && n.isBlock {
+			return n
+		}
+	}
+	return nil
+}
+
+// parseCask parst ein Cask-File und liefert den Inhalt von `cask "token" do ... end`.
+func parseCask(src string) ([]*rbNode, error) {
+	root, err := parseRuby(src)
+	if err != nil {
+		return nil, err
+	}
+	if b := findBlock(root.children, "cask"); b != nil {
+		return b.children, nil
+	}
+	return nil, fmt.Errorf("no cask block found")
+}
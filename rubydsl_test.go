@@ -0,0 +1,333 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Die Fälle decken ab, was rbLex/parseRuby richtig machen müssen, damit extractVersion
+// nur die stable url liest (head/resource/on_* Blöcke, Heredocs und %w Literale ignoriert).
+func TestExtractVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		src          string
+		wantVer      string
+		wantSource   versionSource
+		wantFallback bool // Parser scheitert, Version kommt aus dem Zeilen-Fallback
+	}{
+		{
+			name: "url tarball",
+			src: `class Foo < Formula
+  url "https://example.com/foo-1.2.3.tar.gz"
+  sha256 "abc"
+end
+`,
+			wantVer: "1.2.3", wantSource: sourceURL,
+		},
+		{
+			name: "version overrides url",
+			src: `class Foo < Formula
+  url "https://example.com/foo-latest.tar.gz"
+  version "2.0.1"
+end
+`,
+			wantVer: "2.0.1", wantSource: sourceVersion,
+		},
+		{
+			name: "version stanza after url",
+			src: `class Foo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  version "1.0-rc1"
+end
+`,
+			wantVer: "1.0-rc1", wantSource: sourceVersion,
+		},
+		{
+			name: "head do block before stable url",
+			src: `class Foo < Formula
+  head do
+    url "https://example.com/foo-9.9.9.tar.gz"
+    version "9.9.9"
+  end
+  url "https://example.com/foo-1.2.3.tar.gz"
+end
+`,
+			wantVer: "1.2.3", wantSource: sourceURL,
+		},
+		{
+			name: "head one-liner",
+			src: `class Foo < Formula
+  head "https://github.com/foo/foo.git", branch: "main"
+  url "https://example.com/foo-1.2.3.tar.gz"
+end
+`,
+			wantVer: "1.2.3", wantSource: sourceURL,
+		},
+		{
+			name: "resource do block before stable url",
+			src: `class Foo < Formula
+  resource "bar" do
+    url "https://example.com/bar-0.5.0.tar.gz"
+    sha256 "def"
+  end
+  url "https://example.com/foo-3.1.tar.gz"
+end
+`,
+			wantVer: "3.1", wantSource: sourceURL,
+		},
+		{
+			name: "on_macos do block before stable url",
+			src: `class Foo < Formula
+  on_macos do
+    url "https://example.com/foo-mac-7.0.tar.gz"
+  end
+  url "https://example.com/foo-4.5.6.tar.gz"
+end
+`,
+			wantVer: "4.5.6", wantSource: sourceURL,
+		},
+		{
+			name: "stable do block",
+			src: `class Foo < Formula
+  head "https://github.com/foo/foo.git"
+  stable do
+    url "https://example.com/foo-5.0.tar.gz"
+    resource "bar" do
+      url "https://example.com/bar-1.0.tar.gz"
+    end
+  end
+end
+`,
+			wantVer: "5.0", wantSource: sourceURL,
+		},
+		{
+			name: "git url with tag and revision",
+			src: `class Foo < Formula
+  url "https://github.com/foo/foo.git",
+      tag:      "v1.4.2",
+      revision: "0123456789abcdef0123456789abcdef01234567"
+end
+`,
+			wantVer: "1.4.2", wantSource: sourceTag,
+		},
+		{
+			name: "git url with package prefixed tag",
+			src: `class Foo < Formula
+  url "https://github.com/foo/foo.git", tag: "foo-2.3", revision: "abc"
+end
+`,
+			wantVer: "2.3", wantSource: sourceTag,
+		},
+		{
+			name: "git url with revision only",
+			src: `class Foo < Formula
+  url "https://github.com/foo/foo-1.0.git", revision: "abc"
+end
+`,
+			wantVer: "", wantSource: sourceNone,
+		},
+		{
+			name: "heredoc with do and end and url",
+			src: `class Foo < Formula
+  def caveats
+    <<~EOS
+      url "https://example.com/foo-0.0.1.tar.gz"
+      do not end here
+    EOS
+  end
+  url "https://example.com/foo-1.2.3.tar.gz"
+end
+`,
+			wantVer: "1.2.3", wantSource: sourceURL,
+		},
+		{
+			name: "heredoc as argument",
+			src: `class Foo < Formula
+  url "https://example.com/foo-6.1.tar.gz"
+  def install
+    (bin/"foo").write <<-SH, "x"
+      end
+    SH
+  end
+end
+`,
+			wantVer: "6.1", wantSource: sourceURL,
+		},
+		{
+			name: "percent w literal with end and do",
+			src: `class Foo < Formula
+  ARGS = %w[do end --prefix].freeze
+  url "https://example.com/foo-8.2.tar.gz"
+  def install
+    system "make", *%w(end do)
+  end
+end
+`,
+			wantVer: "8.2", wantSource: sourceURL,
+		},
+		{
+			name: "while and until with do",
+			src: `class Foo < Formula
+  url "https://example.com/foo-3.1.tar.gz"
+  def install
+    while busy? do
+      sleep 1
+    end
+    until ready? do sleep 1 end
+    for f in files do
+      rm f
+    end
+  end
+end
+`,
+			wantVer: "3.1", wantSource: sourceURL,
+		},
+		{
+			name: "endless and setter def",
+			src: `class Foo < Formula
+  def self.mirror_host = "example.com"
+  def caveats() = "none"
+  def prefix=(v)
+    @prefix = v
+  end
+  url "https://example.com/foo-4.0.tar.gz"
+end
+`,
+			wantVer: "4.0", wantSource: sourceURL,
+		},
+		{
+			name: "parser error uses line fallback",
+			src: `class Foo < Formula
+  url "https://example.com/foo-5.2.tar.gz"
+  sha256 "abc"
+`,
+			wantVer: "5.2", wantSource: sourceURL, wantFallback: true,
+		},
+		{
+			name: "line fallback with git tag",
+			src: `class Foo < Formula
+  url "https://github.com/foo/foo.git",
+      tag:      "v5.3.0",
+      revision: "abc"
+`,
+			wantVer: "5.3.0", wantSource: sourceTag, wantFallback: true,
+		},
+		{
+			name: "no url",
+			src: `class Foo < Formula
+  desc "nothing"
+end
+`,
+			wantVer: "", wantSource: sourceNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ver, src, parseErr := extractVersion(tt.src, "foo")
+			if ver != tt.wantVer || src != tt.wantSource {
+				t.Errorf("extractVersion() = %q, %q; want %q, %q", ver, src, tt.wantVer, tt.wantSource)
+			}
+			if (parseErr != nil) != tt.wantFallback {
+				t.Errorf("extractVersion() parse error = %v, want fallback %v", parseErr, tt.wantFallback)
+			}
+		})
+	}
+}
+
+// TestParseFormulaBlocks prüft die Block-Struktur: ein `end` in Heredoc oder %w darf
+// keinen Block schliessen, sonst landen Stanzas im falschen Scope.
+func TestParseFormulaBlocks(t *testing.T) {
+	src := `class Foo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  resource "bar" do
+    url "https://example.com/bar-2.0.tar.gz"
+    patch <<~EOS
+      end
+    EOS
+    args = %w[end end]
+  end
+  sha256 "abc"
+end
+`
+	doc, err := parseFormula(src)
+	if err != nil {
+		t.Fatalf("parseFormula: %v", err)
+	}
+	if doc.parent != "Formula" {
+		t.Errorf("parent = %q, want Formula", doc.parent)
+	}
+	stable := doc.stable()
+	if st := findStanza(stable, "sha256"); st == nil {
+		t.Fatal("sha256 not found in class body (block closed too early?)")
+	} else if v, _ := st.stringValue(); v != "abc" {
+		t.Errorf("sha256 = %q, want abc", v)
+	}
+	res := findBlock(stable, "resource")
+	if res == nil {
+		t.Fatal("resource block not found")
+	}
+	if u, _ := findStanza(res.children, "url").stringValue(); u != "https://example.com/bar-2.0.tar.gz" {
+		t.Errorf("resource url = %q", u)
+	}
+}
+
+// TestFormulaAccessors prüft den Block-Zugriff auf head, resource und depends_on.
+func TestFormulaAccessors(t *testing.T) {
+	src := `class Foo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  head do
+    url "https://github.com/foo/foo.git", branch: "main"
+    depends_on "autoconf"
+  end
+  depends_on "cmake" => :build
+  depends_on :macos
+  depends_on macos: :ventura
+  resource "bar" do
+    url "https://example.com/bar-2.0.tar.gz"
+  end
+  stable do
+    url "https://example.com/foo-1.0.tar.gz"
+    resource "baz" do
+      url "https://example.com/baz-3.0.tar.gz"
+    end
+  end
+  on_linux do
+    depends_on "gcc"
+  end
+end
+`
+	doc, err := parseFormula(src)
+	if err != nil {
+		t.Fatalf("parseFormula: %v", err)
+	}
+
+	if u, _ := findStanza(doc.head(), "url").stringValue(); u != "https://github.com/foo/foo.git" {
+		t.Errorf("head url = %q", u)
+	}
+
+	var names []string
+	for _, r := range doc.resources() {
+		u, _ := findStanza(r.nodes, "url").stringValue()
+		names = append(names, r.name+"="+u)
+	}
+	if got, want := strings.Join(names, " "), "bar=https://example.com/bar-2.0.tar.gz baz=https://example.com/baz-3.0.tar.gz"; got != want {
+		t.Errorf("resources() = %q, want %q", got, want)
+	}
+
+	if got, want := strings.Join(doc.dependsOn(), ","), "cmake,macos,macos"; got != want {
+		t.Errorf("dependsOn() = %q, want %q", got, want)
+	}
+
+	// head als Einzeiler ist selbst das url Statement
+	one, err := parseFormula(`class Foo < Formula
+  head "https://github.com/foo/foo.git", branch: "main"
+end
+`)
+	if err != nil {
+		t.Fatalf("parseFormula: %v", err)
+	}
+	if h := one.head(); len(h) != 1 || h[0].name() != "head" {
+		t.Errorf("head() one-liner = %v", h)
+	}
+}
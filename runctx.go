@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ---- Abbruch: Ctrl-C / SIGTERM und --timeout ----
+//
+// Der Run-Context geht durch alle Git, HTTP und Update Pfade:
+// 1) erstes SIGINT/SIGTERM (oder --timeout) bricht laufende Requests, Clone/Pull/Push ab
+// 2) der Vergleich startet keine neuen Lookups mehr; was fehlt, landet als "not checked" im Report
+// 3) der (Teil-)Report wird trotzdem geschrieben und als incomplete markiert
+// 4) ein zweites Ctrl-C beendet sofort (für den Fall, dass etwas hängt)
+// context.Cause liefert den Grund (interruptedError bzw. errTimeout) für Report und Exit Code.
+
+// interruptedError: der Run wurde per Signal abgebrochen.
+type interruptedError struct {
+	sig os.Signal
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("interrupted (%s)", e.sig)
+}
+
+// errTimeout: --timeout ist abgelaufen.
+var errTimeout = errors.New("timeout")
+
+// newRunContext liefert den Context für den ganzen Run; timeout <= 0 heisst ohne Gesamt-Timeout.
+// stop muss am Ende aufgerufen werden (Signal Handler abmelden, Timer stoppen).
+func newRunContext(timeout time.Duration) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-sigs
+		if !ok {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\n%s: stopping, writing a partial report (press Ctrl-C again to quit immediately)\n", sig)
+		cancel(&interruptedError{sig: sig})
+		if _, ok := <-sigs; ok {
+			os.Exit(exitInterrupted)
+		}
+	}()
+
+	cancelTimeout := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s (--timeout)", errTimeout, timeout))
+	}
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(sigs)
+		cancelTimeout()
+		cancel(nil)
+	}
+}
+
+// abortReason beschreibt, warum ctx beendet wurde ("" = läuft noch),
+// z.B. "interrupted (interrupt)" oder "timeout after 5m0s (--timeout)".
+func abortReason(ctx context.Context) string {
+	if ctx.Err() == nil {
+		return ""
+	}
+	return context.Cause(ctx).Error()
+}
+
+// abortExitCode: 130 nach einem Signal, 124 nach --timeout (siehe errors.go).
+func abortExitCode(ctx context.Context) int {
+	var ie *interruptedError
+	if errors.As(context.Cause(ctx), &ie) {
+		return exitInterrupted
+	}
+	return exitTimeout
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// ---- SSH Transport für den Mirror (Deploy Keys auf CI Agents) ----
+//
+// TAP_URL mit ssh:// oder im scp-Stil (git@bitbucket.org:org/tap.git) läuft über SSH,
+// alles andere über HTTPS (credentials.go). Schlüssel-Auswahl (sshAuth):
+// 1) --ssh-key / TAP_SSH_KEY (Passphrase aus TAP_SSH_KEY_PASSPHRASE)
+// 2) ssh-agent, wenn --ssh-agent gesetzt ist
+// 3) Default Keys ~/.ssh/id_ed25519, id_ecdsa, id_rsa
+// 4) ssh-agent über SSH_AUTH_SOCK, wenn kein Default Key da oder lesbar ist
+//    (z.B. verschlüsselter Key ohne TAP_SSH_KEY_PASSPHRASE, der im Agent geladen ist)
+// Host Keys werden immer gegen known_hosts geprüft (--known-hosts / TAP_SSH_KNOWN_HOSTS,
+// sonst SSH_KNOWN_HOSTS bzw. ~/.ssh/known_hosts); unbekannte Hosts sind ein Fehler.
+
+// sshOptions sind die SSH Einstellungen aus Flags/Env.
+type sshOptions struct {
+	keyFile    string // Pfad zum Private Key
+	passphrase string // nur aus Env, nie als Flag (landet sonst in der Shell History)
+	useAgent   bool   // ssh-agent erzwingen, auch wenn ein Key File gesetzt ist
+	knownHosts string // ":"-getrennte known_hosts Files; "" = go-git Default
+}
+
+// reSCPLike erkennt "user@host:path" ohne Schema (wie git selbst).
+var reSCPLike = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):(.+)$`)
+
+// parseRemoteURL parst eine Git Remote URL; scp-Stil wird zu ssh://user@host/path,
+// lokale Pfade (z.B. Tests gegen ein bare Repo) zu file://.
+func parseRemoteURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		if m := reSCPLike.FindStringSubmatch(raw); m != nil {
+			u := &url.URL{Scheme: "ssh", Host: m[2], Path: "/" + strings.TrimPrefix(m[3], "/")}
+			if m[1] != "" {
+				u.User = url.User(m[1])
+			}
+			return u, nil
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse TAP_URL: %w", err)
+	}
+	if u.Host == "" {
+		return &url.URL{Scheme: "file", Path: strings.TrimPrefix(raw, "file://")}, nil
+	}
+	return u, nil
+}
+
+// remoteAuth wählt die go-git Auth passend zum Schema der URL:
+// ssh -> sshAuth, http(s) -> BasicAuth aus cred (nil = anonym).
+func remoteAuth(tapURL string, cred *credential, opts sshOptions) (transport.AuthMethod, error) {
+	u, err := parseRemoteURL(tapURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "ssh":
+		return sshAuth(u, opts)
+	case "file":
+		return nil, nil
+	}
+	return cred.gitAuth(), nil
+}
+
+// sshAuth baut die SSH Auth inkl. known_hosts Prüfung.
+func sshAuth(u *url.URL, opts sshOptions) (transport.AuthMethod, error) {
+	user := u.User.Username()
+	if user == "" {
+		user = "git"
+	}
+
+	var files []string
+	if opts.knownHosts != "" {
+		files = filepath.SplitList(opts.knownHosts)
+	}
+	hostKeys, err := gitssh.NewKnownHostsCallback(files...)
+	if err != nil {
+		return nil, fmt.Errorf("ssh known_hosts: %w (add the host key, e.g. ssh-keyscan %s >> ~/.ssh/known_hosts)", err, u.Hostname())
+	}
+	helper := gitssh.HostKeyCallbackHelper{HostKeyCallback: hostKeys}
+
+	// 1) explizites Key File
+	if opts.keyFile != "" && !opts.useAgent {
+		keys, err := gitssh.NewPublicKeysFromFile(user, opts.keyFile, opts.passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s: %w", opts.keyFile, err)
+		}
+		keys.HostKeyCallbackHelper = helper
+		return keys, nil
+	}
+
+	agent := func() (transport.AuthMethod, error) {
+		a, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		a.HostKeyCallbackHelper = helper
+		return a, nil
+	}
+
+	// 2) ssh-agent erzwungen
+	if opts.useAgent {
+		return agent()
+	}
+
+	// 3) Default Keys; der erste lesbare gewinnt
+	var keyErr error
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			path := filepath.Join(home, ".ssh", name)
+			if ok, _ := pathExists(path); !ok {
+				continue
+			}
+			keys, err := gitssh.NewPublicKeysFromFile(user, path, opts.passphrase)
+			if err != nil {
+				if keyErr == nil {
+					keyErr = fmt.Errorf("ssh key %s: %w", path, err)
+				}
+				continue
+			}
+			keys.HostKeyCallbackHelper = helper
+			return keys, nil
+		}
+	}
+
+	// 4) kein (lesbarer) Key File -> Agent, falls einer läuft
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		return agent()
+	}
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	return nil, fmt.Errorf("no ssh key for %s: set --ssh-key / TAP_SSH_KEY or start ssh-agent", u.Redacted())
+}
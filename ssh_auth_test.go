@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// TestSSHAuth: Key Files gehen dem Agent vor; der Agent springt nur ein, wenn er erzwungen
+// ist oder kein Key File brauchbar ist.
+func TestSSHAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	tests := []struct {
+		name     string
+		keyFile  []byte // ~/.ssh/id_ed25519; nil = keiner
+		agent    bool   // SSH_AUTH_SOCK zeigt auf einen laufenden "Agent"
+		useAgent bool   // --ssh-agent
+		want     string // "key", "agent" oder Teil der Fehlermeldung
+	}{
+		{name: "key file wins over a running agent", keyFile: validKey, agent: true, want: "key"},
+		{name: "no key file falls back to the agent", agent: true, want: "agent"},
+		{name: "unreadable key falls back to the agent", keyFile: []byte("garbage"), agent: true, want: "agent"},
+		{name: "--ssh-agent forces the agent", keyFile: validKey, agent: true, useAgent: true, want: "agent"},
+		{name: "unreadable key without agent", keyFile: []byte("garbage"), want: "ssh key "},
+		{name: "nothing at all", want: "no ssh key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			knownHosts := filepath.Join(home, ".ssh", "known_hosts")
+			if err := os.WriteFile(knownHosts, nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.keyFile != nil {
+				if err := os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), tt.keyFile, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("SSH_AUTH_SOCK", "")
+			if tt.agent {
+				sock := filepath.Join(home, "agent.sock")
+				l, err := net.Listen("unix", sock)
+				if err != nil {
+					t.Skipf("unix socket: %v", err)
+				}
+				defer l.Close()
+				t.Setenv("SSH_AUTH_SOCK", sock)
+			}
+
+			u := &url.URL{Scheme: "ssh", Host: "bitbucket.org", Path: "/org/tap.git"}
+			auth, err := sshAuth(u, sshOptions{useAgent: tt.useAgent, knownHosts: knownHosts})
+
+			got := ""
+			switch a := auth.(type) {
+			case *gitssh.PublicKeys:
+				got = "key"
+			case *gitssh.PublicKeysCallback:
+				got = "agent"
+			case nil:
+				if err != nil {
+					got = err.Error()
+				}
+			default:
+				got = a.Name()
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("sshAuth() = %q (err %v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ---- Mehrere private Taps in einem Run (--config) ----
+//
+// Ohne Config gibt es genau einen Tap "private" (TAP_URL oder --tap-path, Prefix "gov-"),
+// gespiegelt nach .cache/private-tap wie bisher. Mit Config (JSON) z.B.:
+//
+//	{
+//	  "taps": [
+//	    {"name": "gov",  "url": "https://bitbucket.org/org/homebrew-gov.git", "branch": "main", "prefix": "gov-"},
+//	    {"name": "corp", "url": "git@github.corp.example:org/homebrew-corp.git", "prefix": "corp-", "provider": "github"},
+//	    {"name": "wip",  "path": "/opt/homebrew/Library/Taps/org/homebrew-wip", "prefix": "wip-"}
+//	  ]
+//	}
+//
+// landet jeder url-Tap in .cache/taps/<name>; path-Taps werden direkt gelesen (wie --tap-path).
+
+// defaultTapName / defaultPrefix gelten für den klassischen Single-Tap Mode.
+const (
+	defaultTapName = "private"
+	defaultPrefix  = "gov-"
+)
+
+// tapConfig ist ein Tap-Eintrag aus der Config.
+type tapConfig struct {
+	Name     string `json:"name"`
+	URL      string `json:"url,omitempty"`      // Remote (https, ssh, scp-Stil); sonst path
+	Path     string `json:"path,omitempty"`     // lokales Verzeichnis statt url (kein Git)
+	Branch   string `json:"branch,omitempty"`   // "" = main, Fallback master
+	Prefix   string `json:"prefix,omitempty"`   // wird für den upstream Namen entfernt, z.B. "gov-"
+	Provider string `json:"provider,omitempty"` // wie --provider, nur für diesen Tap
+	APIURL   string `json:"api_url,omitempty"`  // wie --api-url, nur für diesen Tap
+}
+
+// loadedTap ist ein Tap nach Mirror/Scan: Einträge plus Git Host (für --commit/--push/--pr).
+type loadedTap struct {
+	cfg      tapConfig
+	dir      string // Mirror (.cache/...) oder path/--tap-path
+	formulae map[string]localFormula
+	casks    map[string]localFormula
+	unparsed []unparsedEntry
+	ignored  []ignoredEntry // per Config (ignore) ausgenommen
+	remote   tapRemote      // leer bei path-Taps
+}
+
+// ignoredEntry ist ein Tap File, das per Config (ignore) nicht auditiert wird.
+type ignoredEntry struct {
+	tap  string
+	name string
+	kind kind
+	path string
+}
+
+// applyIgnore nimmt alle Einträge raus, die auf ein ignore Pattern passen
+// ("glob" für alle Taps, "<tap>/glob" nur für diesen Tap), und merkt sie sich für den Report.
+func (t *loadedTap) applyIgnore(patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	match := func(name string) bool {
+		for _, p := range patterns {
+			glob := p
+			if tapName, rest, ok := strings.Cut(p, "/"); ok {
+				if tapName != t.cfg.Name {
+					continue
+				}
+				glob = rest
+			}
+			if ok, _ := path.Match(glob, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range []map[string]localFormula{t.formulae, t.casks} {
+		for name, e := range m {
+			if match(name) {
+				t.ignored = append(t.ignored, ignoredEntry{tap: t.cfg.Name, name: name, kind: e.Kind, path: e.Path})
+				delete(m, name)
+			}
+		}
+	}
+	kept := t.unparsed[:0]
+	for _, u := range t.unparsed {
+		if match(u.Name) {
+			t.ignored = append(t.ignored, ignoredEntry{tap: t.cfg.Name, name: u.Name, kind: u.Kind, path: u.Path})
+			continue
+		}
+		kept = append(kept, u)
+	}
+	t.unparsed = kept
+
+	sort.Slice(t.ignored, func(i, j int) bool {
+		if t.ignored[i].kind != t.ignored[j].kind {
+			return t.ignored[i].kind < t.ignored[j].kind
+		}
+		return t.ignored[i].name < t.ignored[j].name
+	})
+}
+
+// lineFallback listet die Formulae, deren Version aus dem Zeilen-Fallback stammt
+// (Parser-Fehler in rubydsl.go), sortiert nach Name; Reason ist der Parser-Fehler.
+func (t *loadedTap) lineFallback() []unparsedEntry {
+	var out []unparsedEntry
+	for name, e := range t.formulae {
+		if e.ParseError != "" {
+			out = append(out, unparsedEntry{Tap: t.cfg.Name, Name: name, Path: e.Path, Kind: e.Kind, Reason: e.ParseError})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// local: path-Tap (Working Copy); dort wird nie committet oder gepusht.
+func (t *loadedTap) local() bool { return t.cfg.Path != "" }
+
+// label ist der Name eines Eintrags in Ausgaben: "gov-abseil" bzw. "corp/corp-abseil" bei mehreren Taps.
+func (t *loadedTap) label(name string, multi bool) string {
+	if multi {
+		return t.cfg.Name + "/" + name
+	}
+	return name
+}
+
+// mirrorDir ist das Cache-Verzeichnis eines url-Taps.
+// Single-Tap ohne Config bleibt bei .cache/private-tap (bestehende Mirrors weiterverwenden).
+func mirrorDir(t tapConfig, fromConfig bool) string {
+	if !fromConfig {
+		return filepath.Join(".cache", "private-tap")
+	}
+	return filepath.Join(".cache", "taps", t.Name)
+}
+
+// tapOpenOptions sind die Einstellungen aus Flags, die für alle Taps gleich sind.
+// - fromConfig: Taps kommen aus --config (Mirror unter .cache/taps/<name>)
+// - providerName: --provider; gilt nur, wenn der Tap selbst keinen Provider setzt
+// - verbose: Herkunft und Auth ausgeben (nur im Text-Mode, damit JSON auf stdout sauber bleibt)
+// - offline: kein clone/pull, der vorhandene Mirror wird so gelesen wie er ist
+type tapOpenOptions struct {
+	fromConfig   bool
+	providerName string
+	ssh          sshOptions
+	verbose      bool
+	offline      bool
+}
+
+// openTap macht einen Tap bereit: path-Taps werden nur geprüft, url-Taps bekommen Provider,
+// Credentials und Git Auth (connectTapRemote) und werden gespiegelt (ensureRepoMirror).
+// Fehler tragen ihre Art (errors.go): config (Pfad, --offline), auth, git (Mirror), parse (Scan).
+func openTap(ctx context.Context, tc tapConfig, opts tapOpenOptions) (*loadedTap, error) {
+	if tc.Path != "" {
+		info, err := os.Stat(tc.Path)
+		if err != nil {
+			return nil, errorf(errConfig, "tap %s: %w", tc.Name, err)
+		}
+		if !info.IsDir() {
+			return nil, errorf(errConfig, "tap %s: %s is not a directory", tc.Name, tc.Path)
+		}
+		if opts.verbose {
+			if opts.fromConfig {
+				fmt.Printf("Tap %s: path %s\n", tc.Name, tc.Path)
+			} else {
+				fmt.Println("Tap path:", tc.Path)
+			}
+		}
+		return scanTap(tc, tc.Path)
+	}
+
+	// offline: nur der letzte Stand des Mirrors, ohne Git Host (kein Provider, keine Auth)
+	if opts.offline {
+		dir := mirrorDir(tc, opts.fromConfig)
+		if ok, _ := pathExists(dir); !ok {
+			return nil, errorf(errConfig, "tap %s: --offline: no mirror in %s (run once without --offline)", tc.Name, dir)
+		}
+		if opts.verbose {
+			fmt.Printf("Tap %s: offline, using mirror %s as is\n", tc.Name, dir)
+		}
+		return scanTap(tc, dir)
+	}
+
+	providerName := opts.providerName
+	if tc.Provider != "" {
+		providerName = tc.Provider
+	}
+	remote, err := connectTapRemote(ctx, tc.URL, providerName, opts.ssh)
+	if err != nil {
+		return nil, fmt.Errorf("tap %s: %w", tc.Name, err)
+	}
+	if opts.verbose {
+		if opts.fromConfig {
+			fmt.Printf("Tap %s: %s\n", tc.Name, tc.URL)
+		}
+		if remote.auth != nil {
+			fmt.Println("Auth:", remote.auth.Name())
+		} else {
+			fmt.Println("Auth: none (anonymous)")
+		}
+		if remote.cred != nil {
+			fmt.Println("Credentials:", remote.cred.source)
+		}
+	}
+
+	dir, err := ensureRepoMirror(ctx, mirrorDir(tc, opts.fromConfig), tc.URL, tc.Branch, remote.auth)
+	if err != nil {
+		return nil, fmt.Errorf("tap %s: %w", tc.Name, gitError(err))
+	}
+	t, err := scanTap(tc, dir)
+	if err != nil {
+		return nil, err
+	}
+	t.remote = remote
+	return t, nil
+}
+
+// scanTap liest Formulae und Casks aus dir und markiert alle Einträge mit Tap und Prefix.
+func scanTap(t tapConfig, dir string) (*loadedTap, error) {
+	formulae, unparsedFormulae, err := loadFormulaEntries(dir)
+	if err != nil {
+		return nil, errorf(errParse, "tap %s: %w", t.Name, err)
+	}
+	casks, unparsedCasks, err := loadCaskEntries(dir)
+	if err != nil {
+		return nil, errorf(errParse, "tap %s: %w", t.Name, err)
+	}
+
+	for _, m := range []map[string]localFormula{formulae, casks} {
+		for name, e := range m {
+			e.Tap, e.Prefix = t.Name, t.Prefix
+			m[name] = e
+		}
+	}
+	unparsed := append(unparsedFormulae, unparsedCasks...)
+	for i := range unparsed {
+		unparsed[i].Tap = t.Name
+	}
+
+	return &loadedTap{cfg: t, dir: dir, formulae: formulae, casks: casks, unparsed: unparsed}, nil
+}
+
+// duplicateEntry: derselbe upstream Eintrag kommt in mehreren Taps vor.
+type duplicateEntry struct {
+	upstream string
+	kind     kind
+	entries  []duplicateRef
+}
+
+// duplicateRef ist ein Vorkommen eines Duplikats.
+type duplicateRef struct {
+	tap  string
+	name string
+	path string
+}
+
+// findDuplicates sucht Formulae/Casks, die in mehr als einem Tap auf denselben upstream Namen zeigen
+// (z.B. gov/gov-abseil und corp/corp-abseil). Innerhalb eines Taps ist das kein Duplikat.
+// Die Vorkommen stehen in Config-Reihenfolge.
+func findDuplicates(taps []*loadedTap, rules nameRules) []duplicateEntry {
+	type key struct {
+		k        kind
+		upstream string
+	}
+	byKey := map[key][]duplicateRef{}
+	tapOrder := map[string]int{}
+	for i, t := range taps {
+		tapOrder[t.cfg.Name] = i
+		for _, m := range []map[string]localFormula{t.formulae, t.casks} {
+			for name, e := range m {
+				k := key{e.Kind, rules.toUpstreamName(name, e.Prefix, e.Kind)}
+				byKey[k] = append(byKey[k], duplicateRef{tap: t.cfg.Name, name: name, path: e.Path})
+			}
+		}
+	}
+
+	var out []duplicateEntry
+	for k, refs := range byKey {
+		tapsSeen := map[string]bool{}
+		for _, r := range refs {
+			tapsSeen[r.tap] = true
+		}
+		if len(tapsSeen) < 2 {
+			continue
+		}
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].tap != refs[j].tap {
+				return tapOrder[refs[i].tap] < tapOrder[refs[j].tap]
+			}
+			return refs[i].name < refs[j].name
+		})
+		out = append(out, duplicateEntry{upstream: k.upstream, kind: k.k, entries: refs})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].kind != out[j].kind {
+			return out[i].kind < out[j].kind
+		}
+		return out[i].upstream < out[j].upstream
+	})
+	return out
+}
+
+// String liefert z.B. "abseil: gov/gov-abseil, corp/corp-abseil".
+func (d duplicateEntry) String() string {
+	refs := make([]string, len(d.entries))
+	for i, r := range d.entries {
+		refs[i] = r.tap + "/" + r.name
+	}
+	prefix := ""
+	if d.kind == kindCask {
+		prefix = "cask "
+	}
+	return prefix + d.upstream + ": " + strings.Join(refs, ", ")
+}
+
+// forTap liefert den Teil des Reports, der zu einem Tap gehört (für die Gruppierung pro Tap),
+// inkl. der Duplikate, an denen der Tap beteiligt ist.
+// Die Zähler ergeben sich aus den Zeilen: jeder gescannte Eintrag landet in genau einer Liste.
+func (rep report) forTap(name string) report {
+	out := report{generatedAt: rep.generatedAt, taps: []string{name}, incomplete: rep.incomplete}
+	count := func(k kind) {
+		if k == kindCask {
+			out.caskCount++
+		} else {
+			out.privateCount++
+		}
+	}
+	for _, r := range rep.behind {
+		if r.tap == name {
+			out.behind = append(out.behind, r)
+			count(r.kind)
+		}
+	}
+	for _, r := range rep.upToDate {
+		if r.tap == name {
+			out.upToDate = append(out.upToDate, r)
+			count(r.kind)
+		}
+	}
+	for _, nf := range rep.notFound {
+		if nf.tap == name {
+			out.notFound = append(out.notFound, nf)
+			count(nf.kind)
+		}
+	}
+	for _, le := range rep.errorsList {
+		if le.tap == name {
+			out.errorsList = append(out.errorsList, le)
+			count(le.kind)
+		}
+	}
+	for _, nc := range rep.notChecked {
+		if nc.tap == name {
+			out.notChecked = append(out.notChecked, nc)
+			count(nc.kind)
+		}
+	}
+	for _, u := range rep.unparsed {
+		if u.Tap == name {
+			out.unparsed = append(out.unparsed, u)
+		}
+	}
+	for _, u := range rep.lineFallback {
+		if u.Tap == name {
+			out.lineFallback = append(out.lineFallback, u)
+		}
+	}
+	for _, ig := range rep.ignored {
+		if ig.tap == name {
+			out.ignored = append(out.ignored, ig)
+		}
+	}
+	for _, d := range rep.duplicates {
+		for _, e := range d.entries {
+			if e.tap == name {
+				out.duplicates = append(out.duplicates, d)
+				break
+			}
+		}
+	}
+	return out
+}
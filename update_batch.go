@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// updateResult ist das Ergebnis eines Updates (eine Formula) im Batch-Mode.
+type updateResult struct {
+	privateName string
+	bumped      *bumpedFormula // gesetzt, wenn --apply das File wirklich geändert hat
+	err         error          // nil = ok
+}
+
+// updateTarget ist eine zu aktualisierende Formula in einem bestimmten Tap.
+type updateTarget struct {
+	tap   *loadedTap
+	name  string // Name im Tap, z.B. "gov-abseil"
+	label string // Anzeige: name bzw. "tap/name" bei mehreren Taps
+}
+
+// selectUpdateTargets bestimmt, welche privaten Formulae aktualisiert werden.
+//
+// - all=true (--update-all): alle Formulae aus rep.behind (Casks haben keinen Updater)
+// - spec (--update): Komma-Liste aus Namen und/oder Globs, z.B. "gov-abseil,gov-llvm*"
+//   - Name:  muss in einem Tap existieren, sonst Fehler im Ergebnis (kein Abbruch);
+//     liegt er in mehreren Taps, muss der Tap dazu: "corp/corp-abseil"
+//   - Glob:  matcht gegen alle privaten Formulae (optional auf einen Tap begrenzt: "corp/*");
+//     kein Treffer -> Fehler im Ergebnis
+//
+// Rückgabe: sortierte, eindeutige Ziele plus Fehler für unbekannte Namen/Globs.
+func selectUpdateTargets(spec string, all bool, taps []*loadedTap, rep report) ([]updateTarget, []updateResult) {
+	multi := len(taps) > 1
+	byName := map[string]*loadedTap{}
+	for _, t := range taps {
+		byName[t.cfg.Name] = t
+	}
+
+	seen := map[string]updateTarget{}
+	add := func(t *loadedTap, name string) {
+		label := t.label(name, multi)
+		seen[label] = updateTarget{tap: t, name: name, label: label}
+	}
+	var failed []updateResult
+
+	if all {
+		for _, r := range rep.behind {
+			if t := byName[r.tap]; t != nil && r.kind == kindFormula {
+				add(t, r.privateName)
+			}
+		}
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// Optional "tap/..." -> nur in diesem Tap suchen
+		scope := taps
+		pattern := item
+		if tapName, rest, ok := strings.Cut(item, "/"); ok {
+			t := byName[tapName]
+			if t == nil {
+				failed = append(failed, updateResult{privateName: item, err: fmt.Errorf("unknown tap %q", tapName)})
+				continue
+			}
+			scope, pattern = []*loadedTap{t}, rest
+		}
+
+		// Kein Glob -> exakter Name
+		if !strings.ContainsAny(pattern, "*?[") {
+			var found []*loadedTap
+			for _, t := range scope {
+				if _, ok := t.formulae[pattern]; ok {
+					found = append(found, t)
+				}
+			}
+			switch len(found) {
+			case 0:
+				failed = append(failed, updateResult{privateName: item, err: fmt.Errorf("unknown private formula")})
+			case 1:
+				add(found[0], pattern)
+			default:
+				failed = append(failed, updateResult{privateName: item, err: fmt.Errorf("formula exists in %d taps, use <tap>/%s", len(found), pattern)})
+			}
+			continue
+		}
+
+		// Glob einmal prüfen; ein kaputtes Pattern ist genau ein Fehler
+		if _, err := path.Match(pattern, ""); err != nil {
+			failed = append(failed, updateResult{privateName: item, err: fmt.Errorf("bad pattern: %w", err)})
+			continue
+		}
+		matched := false
+		for _, t := range scope {
+			for name := range t.formulae {
+				if ok, _ := path.Match(pattern, name); ok {
+					add(t, name)
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			failed = append(failed, updateResult{privateName: item, err: fmt.Errorf("pattern matches no private formula")})
+		}
+	}
+
+	targets := make([]updateTarget, 0, len(seen))
+	for _, t := range seen {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].label < targets[j].label })
+	return targets, failed
+}
+
+// runUpdates führt updateOne für jede Formula aus und sammelt die Ergebnisse.
+// Ein Fehler stoppt den Batch nicht; er landet im Ergebnis der jeweiligen Formula.
+// Nach Ctrl-C / --timeout wird nichts mehr gestartet; die restlichen Formulae sind "not started".
+func runUpdates(ctx context.Context, client *http.Client, targets []updateTarget, opts updateOptions) []updateResult {
+	results := make([]updateResult, 0, len(targets))
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			results = append(results, updateResult{privateName: t.label, err: fmt.Errorf("not started: %s", abortReason(ctx))})
+			continue
+		}
+		bumped, err := updateOne(ctx, client, t, opts)
+		results = append(results, updateResult{privateName: t.label, bumped: bumped, err: err})
+	}
+	return results
+}
+
+// printUpdateSummary gibt am Ende eines Batch-Updates eine Übersicht aus
+// und meldet, ob mindestens ein Update fehlgeschlagen ist.
+func printUpdateSummary(results []updateResult, apply bool) (anyFailed bool) {
+	var ok, failed []updateResult
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		} else {
+			ok = append(ok, r)
+		}
+	}
+
+	verb := "Previewed"
+	if apply {
+		verb = "Updated"
+	}
+
+	fmt.Fprintln(updateOut, "=== Update Summary ===")
+	for _, r := range ok {
+		fmt.Fprintf(updateOut, " ok:     %s\n", r.privateName)
+	}
+	for _, r := range failed {
+		fmt.Fprintf(updateOut, " failed: %s: %v\n", r.privateName, r.err)
+	}
+	fmt.Fprintf(updateOut, "%s %d/%d (%d failed)\n\n", verb, len(ok), len(results), len(failed))
+
+	return len(failed) > 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSelectUpdateTargets: Namen, Globs und Tap-Scope ("tap/...") für --update / --update-all.
+func TestSelectUpdateTargets(t *testing.T) {
+	newTap := func(name string, formulae ...string) *loadedTap {
+		t := &loadedTap{cfg: tapConfig{Name: name}, formulae: map[string]localFormula{}}
+		for _, f := range formulae {
+			t.formulae[f] = localFormula{Kind: kindFormula}
+		}
+		return t
+	}
+	corp := newTap("corp", "abseil", "corp-llvm", "corp-llvm-15", "shared")
+	gov := newTap("gov", "gov-abseil", "gov-llvm", "shared")
+	rep := report{behind: []behindRow{
+		{tap: "gov", privateName: "gov-abseil", kind: kindFormula},
+		{tap: "corp", privateName: "corp-llvm", kind: kindFormula},
+		{tap: "gov", privateName: "gov-app", kind: kindCask},
+	}}
+
+	tests := []struct {
+		name       string
+		spec       string
+		all        bool
+		taps       []*loadedTap
+		want       string // Labels, mit Leerzeichen getrennt
+		wantFailed string // "item: Fehler", mit "; " getrennt
+	}{
+		{
+			name: "single tap labels without prefix",
+			spec: "gov-abseil, gov-llvm",
+			taps: []*loadedTap{gov},
+			want: "gov-abseil gov-llvm",
+		},
+		{
+			name: "name unique across taps",
+			spec: "abseil",
+			taps: []*loadedTap{corp, gov},
+			want: "corp/abseil",
+		},
+		{
+			name:       "name in several taps needs the tap",
+			spec:       "shared,gov/shared",
+			taps:       []*loadedTap{corp, gov},
+			want:       "gov/shared",
+			wantFailed: "shared: formula exists in 2 taps, use <tap>/shared",
+		},
+		{
+			name: "glob over all taps",
+			spec: "*llvm*",
+			taps: []*loadedTap{corp, gov},
+			want: "corp/corp-llvm corp/corp-llvm-15 gov/gov-llvm",
+		},
+		{
+			name: "glob scoped to a tap",
+			spec: "gov/*",
+			taps: []*loadedTap{corp, gov},
+			want: "gov/gov-abseil gov/gov-llvm gov/shared",
+		},
+		{
+			name:       "scoped name only in the other tap",
+			spec:       "corp/gov-llvm",
+			taps:       []*loadedTap{corp, gov},
+			wantFailed: "corp/gov-llvm: unknown private formula",
+		},
+		{
+			name:       "unknown tap, bad pattern and empty glob",
+			spec:       "x/foo,[,zzz*",
+			taps:       []*loadedTap{corp, gov},
+			wantFailed: `x/foo: unknown tap "x"; [: bad pattern: syntax error in pattern; zzz*: pattern matches no private formula`,
+		},
+		{
+			name: "all takes behind formulae, spec adds and dedupes",
+			spec: "gov/gov-abseil,corp/shared",
+			all:  true,
+			taps: []*loadedTap{corp, gov},
+			want: "corp/corp-llvm corp/shared gov/gov-abseil",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, failed := selectUpdateTargets(tt.spec, tt.all, tt.taps, rep)
+			var labels, errs []string
+			for _, tg := range targets {
+				labels = append(labels, tg.label)
+			}
+			for _, f := range failed {
+				errs = append(errs, f.privateName+": "+f.err.Error())
+			}
+			if got := strings.Join(labels, " "); got != tt.want {
+				t.Errorf("targets = %q, want %q", got, tt.want)
+			}
+			if got := strings.Join(errs, "; "); got != tt.wantFailed {
+				t.Errorf("failed = %q, want %q", got, tt.wantFailed)
+			}
+		})
+	}
+}
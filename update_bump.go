@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// updateMode bestimmt, wie updateOne das private File erzeugt.
+type updateMode string
+
+const (
+	// updateBump behält dein File und schreibt nur url/sha256/version der stable Spec um
+	// (revision wird zurückgesetzt). Patches, extra depends_on, bottle root_url etc. bleiben.
+	updateBump updateMode = "bump"
+	// updateReplace ersetzt das File komplett durch das Upstream .rb (nur class-Zeile angepasst).
+	updateReplace updateMode = "replace"
+)
+
+// parseUpdateMode prüft den Wert von --update-mode.
+func parseUpdateMode(s string) (updateMode, error) {
+	switch m := updateMode(s); m {
+	case updateBump, updateReplace:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown --update-mode %q (want bump or replace)", s)
+}
+
+// textEdit ersetzt src[start:end] durch text. Mehrere Edits dürfen sich nicht überlappen.
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits wendet alle Edits auf src an (von hinten nach vorne, damit die Offsets stimmen).
+func applyEdits(src string, edits []textEdit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = src[:e.start] + e.text + src[e.end:]
+	}
+	return src
+}
+
+// bumpFormula schreibt die stable Spec von privateSrc auf den Stand von upstreamSrc um.
+//
+// Geändert wird nur (jeweils in der stable Spec, siehe formulaDoc.stable):
+// - url "..." inkl. tag:/revision: Argumente
+// - mirror "..." (auf das neue Archiv, siehe bumpMirrors)
+// - sha256 "..." (ersetzt, eingefügt oder entfernt, je nach Upstream)
+// - version "..." (ersetzt oder eingefügt, wenn Upstream eine explizite Version hat)
+// - revision N wird entfernt (neue Version -> Revision startet wieder bei 0)
+//
+// Alles andere bleibt byte-genau gleich. Passt die Form der url nicht zusammen
+// (z.B. privat Tarball, upstream git tag), gibt es einen Fehler statt eines halben Rewrites.
+// Versteht der Parser eines der Files nicht, übernimmt bumpFormulaLines.
+func bumpFormula(privateSrc, upstreamSrc, pkgName string) (string, error) {
+	priv, err := parseFormula(privateSrc)
+	if err != nil {
+		return bumpFormulaLines(privateSrc, upstreamSrc, pkgName, fmt.Errorf("parse private formula: %w", err))
+	}
+	up, err := parseFormula(upstreamSrc)
+	if err != nil {
+		return bumpFormulaLines(privateSrc, upstreamSrc, pkgName, fmt.Errorf("parse upstream formula: %w", err))
+	}
+
+	pStable, uStable := priv.stable(), up.stable()
+
+	pURL := findStanza(pStable, "url")
+	uURL := findStanza(uStable, "url")
+	if pURL == nil || uURL == nil {
+		return "", fmt.Errorf("stable url missing (private: %v, upstream: %v)", pURL != nil, uURL != nil)
+	}
+
+	var edits []textEdit
+
+	// 1) url "..." selbst
+	newURL, ok := uURL.stringValue()
+	if !ok {
+		return "", fmt.Errorf("upstream url is not a plain string literal")
+	}
+	pTok := pURL.first().toks[0]
+	edits = append(edits, textEdit{pTok.start, pTok.end, rbQuote(newURL)})
+
+	//    mirror zeigen auf dasselbe Archiv wie url; bleiben sie alt, passt der sha256 nicht mehr
+	oldURL, _ := pURL.stringValue()
+	mirrorEdits, err := bumpMirrors(pStable, uStable, oldURL, newURL, pkgName)
+	if err != nil {
+		return "", err
+	}
+	edits = append(edits, mirrorEdits...)
+
+	// 2) tag:/revision: müssen in beiden Files gleich vorhanden sein
+	for _, key := range []string{"tag", "revision"} {
+		ua, pa := uURL.kw(key), pURL.kw(key)
+		switch {
+		case ua == nil && pa == nil:
+			continue
+		case ua == nil:
+			return "", fmt.Errorf("url shape differs: %s: only in private formula (use --update-mode replace)", key)
+		case pa == nil:
+			return "", fmt.Errorf("url shape differs: %s: only in upstream formula (use --update-mode replace)", key)
+		}
+		v, ok := ua.str()
+		if !ok || len(pa.toks) != 1 {
+			return "", fmt.Errorf("url %s: is not a plain string literal", key)
+		}
+		edits = append(edits, textEdit{pa.toks[0].start, pa.toks[0].end, rbQuote(v)})
+	}
+
+	// 3) sha256 / version: ersetzen, einfügen (nach der url) oder entfernen
+	indent := lineIndent(privateSrc, pURL.node.start)
+	for _, name := range []string{"sha256", "version"} {
+		us, ps := findStanza(uStable, name), findStanza(pStable, name)
+
+		var want string
+		switch {
+		case us != nil:
+			v, ok := us.stringValue()
+			if !ok {
+				return "", fmt.Errorf("upstream %s is not a plain string literal", name)
+			}
+			want = v
+		case name == "version" && ps != nil:
+			// Upstream leitet die Version aus der url ab; deine explizite Version nachziehen
+			v, _, _ := extractVersion(upstreamSrc, pkgName)
+			if v == "" {
+				return "", fmt.Errorf("cannot determine upstream version for explicit version stanza")
+			}
+			want = v
+		case ps != nil:
+			// Upstream hat keinen sha256 mehr (z.B. git url) -> Zeile entfernen
+			edits = append(edits, removeLine(privateSrc, ps.node))
+			continue
+		default:
+			continue
+		}
+
+		if ps != nil {
+			tok := ps.first().toks[0]
+			edits = append(edits, textEdit{tok.start, tok.end, rbQuote(want)})
+		} else {
+			// hinter dem Zeilenende der url einfügen, sonst landet ein Kommentar danach auf der neuen Zeile
+			at := lineEnd(privateSrc, pURL.node.end)
+			edits = append(edits, textEdit{at, at, "\n" + indent + name + " " + rbQuote(want)})
+		}
+	}
+
+	// 4) revision N zurücksetzen
+	if rev := findStanza(pStable, "revision"); rev != nil {
+		edits = append(edits, removeLine(privateSrc, rev.node))
+	}
+
+	// Zwei Edits am selben Offset (z.B. sha256 und version nach der url) in fester Reihenfolge:
+	// applyEdits arbeitet von hinten, darum hier zusammenführen.
+	return applyEdits(privateSrc, mergeInserts(edits)), nil
+}
+
+// reLineMirror / reLineRevision: mirror bzw. revision N Zeile (für den Zeilen-Fallback)
+var (
+	reLineMirror   = regexp.MustCompile(`(?m)^\s*mirror\s`)
+	reLineRevision = regexp.MustCompile(`(?m)^[ \t]*revision\s+\d+[ \t]*(?:#.*)?\n`)
+)
+
+// bumpFormulaLines ist der Zeilen-Fallback von bumpFormula (erste url/sha256/version Zeile im File).
+// Bewusst eng: nur Tarball urls ohne weitere Argumente und ohne mirror; alles andere ist
+// ein Fehler mit Hinweis auf --update-mode replace, statt halb geraten umzuschreiben.
+func bumpFormulaLines(privateSrc, upstreamSrc, pkgName string, parseErr error) (string, error) {
+	fail := func(what string) error {
+		return fmt.Errorf("%w; line fallback: %s (use --update-mode replace)", parseErr, what)
+	}
+
+	pLoc := reLineURL.FindStringSubmatchIndex(privateSrc)
+	_, pStart, pEnd, _ := lineURL(privateSrc)
+	newURL, uStart, uEnd, ok := lineURL(upstreamSrc)
+	if pLoc == nil || !ok {
+		return "", fail("stable url missing")
+	}
+	for _, stmt := range []string{privateSrc[pStart:pEnd], upstreamSrc[uStart:uEnd]} {
+		if strings.Contains(stmt, ",") || strings.Contains(stmt, ".git\"") {
+			return "", fail("only plain archive urls are supported")
+		}
+	}
+	if reLineMirror.MatchString(privateSrc) {
+		return "", fail("mirror lines are not supported")
+	}
+
+	// 1) url: komplettes Literal ersetzen (auch '...' wird zu "...")
+	edits := []textEdit{{pLoc[2] - 1, pLoc[3] + 1, rbQuote(newURL)}}
+
+	// 2) sha256 / version: ersetzen oder nach der url Zeile einfügen
+	indent := lineIndent(privateSrc, pStart)
+	upVer := ""
+	if m := reLineVersion.FindStringSubmatch(upstreamSrc); m != nil {
+		upVer = m[1]
+	}
+	upSum := ""
+	if m := reLineSHA256.FindStringSubmatch(upstreamSrc); m != nil {
+		upSum = m[1]
+	}
+	for _, st := range []struct {
+		name string
+		re   *regexp.Regexp
+		want string
+	}{{"sha256", reLineSHA256, upSum}, {"version", reLineVersion, upVer}} {
+		loc := st.re.FindStringSubmatchIndex(privateSrc)
+		want := st.want
+		if want == "" && loc != nil && st.name == "version" {
+			// Upstream leitet die Version aus der url ab; deine explizite Version nachziehen
+			want, _ = extractVersionLines(upstreamSrc, pkgName)
+		}
+		switch {
+		case want == "" && loc != nil:
+			return "", fail("cannot determine upstream " + st.name)
+		case want == "":
+			continue
+		case loc != nil:
+			edits = append(edits, textEdit{loc[2] - 1, loc[3] + 1, rbQuote(want)})
+		default:
+			edits = append(edits, textEdit{pEnd, pEnd, indent + st.name + " " + rbQuote(want) + "\n"})
+		}
+	}
+
+	// 3) revision N zurücksetzen
+	if loc := reLineRevision.FindStringIndex(privateSrc); loc != nil {
+		edits = append(edits, textEdit{loc[0], loc[1], ""})
+	}
+	return applyEdits(privateSrc, mergeInserts(edits)), nil
+}
+
+// bumpMirrors schreibt die mirror Stanzas der stable Spec auf das neue Archiv um.
+// Pro privatem mirror, in dieser Reihenfolge:
+// 1) upstream hat einen mirror auf demselben Host -> den übernehmen
+// 2) die alte Version steht in der mirror URL -> durch die neue ersetzen
+// 3) sonst Fehler: ein mirror auf das alte Archiv passt nicht zum neuen sha256
+func bumpMirrors(pStable, uStable []*rbNode, oldURL, newURL, pkgName string) ([]textEdit, error) {
+	if oldURL == newURL {
+		return nil, nil
+	}
+	upByHost := map[string]string{}
+	for _, m := range findStanzas(uStable, "mirror") {
+		if v, ok := m.stringValue(); ok {
+			if h := urlHost(v); h != "" && upByHost[h] == "" {
+				upByHost[h] = v
+			}
+		}
+	}
+	oldVer, newVer := inferVersionFromURL(oldURL, pkgName), inferVersionFromURL(newURL, pkgName)
+
+	var edits []textEdit
+	for _, m := range findStanzas(pStable, "mirror") {
+		cur, ok := m.stringValue()
+		if !ok {
+			return nil, fmt.Errorf("mirror is not a plain string literal")
+		}
+		want, found := upByHost[urlHost(cur)]
+		if !found && oldVer != "" && newVer != "" && strings.Contains(cur, oldVer) {
+			want, found = strings.ReplaceAll(cur, oldVer, newVer), true
+		}
+		if !found {
+			return nil, fmt.Errorf("mirror %s: cannot map to the new version (fix it by hand or use --update-mode replace)", cur)
+		}
+		tok := m.first().toks[0]
+		edits = append(edits, textEdit{tok.start, tok.end, rbQuote(want)})
+	}
+	return edits, nil
+}
+
+// urlHost liefert den Host einer URL ("" wenn nicht parsebar).
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// mergeInserts fasst reine Einfügungen am selben Offset zu einem Edit zusammen
+// (Reihenfolge wie in edits), damit applyEdits sie nicht vertauscht.
+func mergeInserts(edits []textEdit) []textEdit {
+	var out []textEdit
+	byPos := map[int]int{}
+	for _, e := range edits {
+		if e.start == e.end {
+			if i, ok := byPos[e.start]; ok {
+				out[i].text += e.text
+				continue
+			}
+			byPos[e.start] = len(out)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// removeLine entfernt die komplette(n) Zeile(n) eines Statements inkl. Newline.
+func removeLine(src string, n *rbNode) textEdit {
+	start := strings.LastIndexByte(src[:n.start], '\n') + 1
+	end := len(src)
+	if i := strings.IndexByte(src[n.end:], '\n'); i != -1 {
+		end = n.end + i + 1
+	}
+	return textEdit{start, end, ""}
+}
+
+// lineIndent liefert die Einrückung der Zeile, in der offset liegt.
+func lineIndent(src string, offset int) string {
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+	i := start
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return src[start:i]
+}
+
+// lineEnd liefert den Offset des Newlines der Zeile, in der offset liegt (len(src) in der letzten Zeile).
+func lineEnd(src string, offset int) int {
+	if i := strings.IndexByte(src[offset:], '\n'); i != -1 {
+		return offset + i
+	}
+	return len(src)
+}
+
+// rbQuote macht aus s ein Ruby "..." Literal (ohne ungewollte Interpolation).
+func rbQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `#{`, `\#{`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBumpFormulaLines: Files, an denen der Parser scheitert, gehen über den Zeilen-Fallback;
+// alles, was dort nicht sicher umschreibbar ist, muss ein Fehler sein.
+func TestBumpFormulaLines(t *testing.T) {
+	upstream := `class Foo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+end
+`
+	tests := []struct {
+		name    string
+		private string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "replace url and sha256, drop revision",
+			private: `class GovFoo < Formula
+  url 'https://example.com/foo-1.0.tar.gz'
+  sha256 "old"
+  revision 2
+`,
+			want: `class GovFoo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+`,
+		},
+		{
+			name: "insert sha256 after url",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-1.0.tar.gz" # pinned
+`,
+			want: `class GovFoo < Formula
+  url "https://example.com/foo-2.0.tar.gz" # pinned
+  sha256 "new"
+`,
+		},
+		{
+			name: "explicit version follows url",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  version "1.0"
+  sha256 "old"
+`,
+			want: `class GovFoo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  version "2.0"
+  sha256 "new"
+`,
+		},
+		{
+			name: "git url is refused",
+			private: `class GovFoo < Formula
+  url "https://github.com/foo/foo.git",
+      tag: "v1.0"
+`,
+			wantErr: "only plain archive urls",
+		},
+		{
+			name: "mirror is refused",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  mirror "https://mirror.example.com/foo-1.0.tar.gz"
+`,
+			wantErr: "mirror lines",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bumpFormula(tt.private, upstream, "foo")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("bumpFormula() error = %v, want %q", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), "never closed") {
+					t.Errorf("bumpFormula() error = %v, want the parser error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bumpFormula() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("bumpFormula() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetStableSHA256: sha256 ersetzen bzw. nach der url Zeile einfügen, mit und ohne Parser.
+func TestSetStableSHA256(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "parsed insert after url comment",
+			src:  "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\" # main\n  license \"MIT\"\nend\n",
+			want: "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\" # main\n  sha256 \"new\"\n  license \"MIT\"\nend\n",
+		},
+		{
+			name: "parsed replace in stable block",
+			src:  "class Foo < Formula\n  stable do\n    url \"https://example.com/foo-1.0.tar.gz\"\n    sha256 \"old\"\n  end\nend\n",
+			want: "class Foo < Formula\n  stable do\n    url \"https://example.com/foo-1.0.tar.gz\"\n    sha256 \"new\"\n  end\nend\n",
+		},
+		{
+			name: "line fallback replace",
+			src:  "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\"\n  sha256 \"old\"\n",
+			want: "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\"\n  sha256 \"new\"\n",
+		},
+		{
+			name: "line fallback insert",
+			src:  "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\" # main\n",
+			want: "class Foo < Formula\n  url \"https://example.com/foo-1.0.tar.gz\" # main\n  sha256 \"new\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setStableSHA256(tt.src, "new")
+			if err != nil {
+				t.Fatalf("setStableSHA256() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("setStableSHA256() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBumpFormula: nur die stable Spec wird umgeschrieben, der Rest bleibt byte-genau.
+func TestBumpFormula(t *testing.T) {
+	tests := []struct {
+		name     string
+		private  string
+		upstream string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "replace url and sha256, drop revision, keep head",
+			private: `class GovFoo < Formula
+  desc "foo"
+  url "https://example.com/foo-1.0.tar.gz"
+  sha256 "old"
+  revision 2
+  head "https://github.com/foo/foo.git", branch: "main"
+
+  def install
+    system "make"
+  end
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+end
+`,
+			want: `class GovFoo < Formula
+  desc "foo"
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+  head "https://github.com/foo/foo.git", branch: "main"
+
+  def install
+    system "make"
+  end
+end
+`,
+		},
+		{
+			name: "insert sha256 and version after the url comment",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-latest.tar.gz" # internal copy
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://example.com/foo-latest.tar.gz?v=2"
+  version "2.0"
+  sha256 "new"
+end
+`,
+			want: `class GovFoo < Formula
+  url "https://example.com/foo-latest.tar.gz?v=2" # internal copy
+  sha256 "new"
+  version "2.0"
+end
+`,
+		},
+		{
+			name: "explicit version follows the upstream url",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  version "1.0"
+  sha256 "old"
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+end
+`,
+			want: `class GovFoo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  version "2.0"
+  sha256 "new"
+end
+`,
+		},
+		{
+			name: "git url with tag and revision, stray sha256 removed",
+			private: `class GovFoo < Formula
+  url "https://github.com/foo/foo.git",
+      tag:      "v1.0",
+      revision: "aaa"
+  sha256 "old"
+  revision 1
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://github.com/foo/foo.git",
+      tag:      "v2.0",
+      revision: "bbb"
+end
+`,
+			want: `class GovFoo < Formula
+  url "https://github.com/foo/foo.git",
+      tag:      "v2.0",
+      revision: "bbb"
+end
+`,
+		},
+		{
+			name: "stable block",
+			private: `class GovFoo < Formula
+  stable do
+    url "https://example.com/foo-1.0.tar.gz"
+    sha256 "old"
+  end
+  resource "bar" do
+    url "https://example.com/bar-1.0.tar.gz"
+    sha256 "bar"
+  end
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://example.com/foo-2.0.tar.gz"
+  sha256 "new"
+end
+`,
+			want: `class GovFoo < Formula
+  stable do
+    url "https://example.com/foo-2.0.tar.gz"
+    sha256 "new"
+  end
+  resource "bar" do
+    url "https://example.com/bar-1.0.tar.gz"
+    sha256 "bar"
+  end
+end
+`,
+		},
+		{
+			name: "shape mismatch tarball vs git tag",
+			private: `class GovFoo < Formula
+  url "https://example.com/foo-1.0.tar.gz"
+  sha256 "old"
+end
+`,
+			upstream: `class Foo < Formula
+  url "https://github.com/foo/foo.git", tag: "v2.0", revision: "bbb"
+end
+`,
+			wantErr: "tag: only in upstream formula",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bumpFormula(tt.private, tt.upstream, "foo")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("bumpFormula() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bumpFormula() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("bumpFormula() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBumpMirrors deckt die drei Wege ab: upstream mirror gleicher Host, Version ersetzen, Fehler.
+func TestBumpMirrors(t *testing.T) {
+	const oldURL, newURL = "https://example.com/foo-1.0.tar.gz", "https://example.com/foo-2.0.tar.gz"
+	tests := []struct {
+		name     string
+		private  string // mirror Zeilen im privaten File
+		upstream string // mirror Zeilen im Upstream File
+		newURL   string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:     "same host from upstream",
+			private:  `mirror "https://mirror.example.org/old/foo.tgz"`,
+			upstream: `mirror "https://mirror.example.org/pub/foo-2.0.tgz"`,
+			newURL:   newURL,
+			want:     []string{`"https://mirror.example.org/pub/foo-2.0.tgz"`},
+		},
+		{
+			name:    "version swapped",
+			private: `mirror "https://ftp.example.net/foo/foo-1.0.tar.gz"`,
+			newURL:  newURL,
+			want:    []string{`"https://ftp.example.net/foo/foo-2.0.tar.gz"`},
+		},
+		{
+			name:    "unchanged url",
+			private: `mirror "https://ftp.example.net/foo/latest.tar.gz"`,
+			newURL:  oldURL,
+		},
+		{
+			name:    "cannot map",
+			private: `mirror "https://ftp.example.net/foo/latest.tar.gz"`,
+			newURL:  newURL,
+			wantErr: "cannot map to the new version",
+		},
+	}
+
+	parse := func(t *testing.T, url, mirrors string) []*rbNode {
+		t.Helper()
+		doc, err := parseFormula("class Foo < Formula\n  url \"" + url + "\"\n  " + mirrors + "\nend\n")
+		if err != nil {
+			t.Fatalf("parseFormula: %v", err)
+		}
+		return doc.stable()
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits, err := bumpMirrors(parse(t, oldURL, tt.private), parse(t, tt.newURL, tt.upstream), oldURL, tt.newURL, "foo")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("bumpMirrors() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bumpMirrors() error = %v", err)
+			}
+			var got []string
+			for _, e := range edits {
+				got = append(got, e.text)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("bumpMirrors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyEdits: Edits in beliebiger Reihenfolge, Einfügungen am selben Offset in Listen-Reihenfolge.
+func TestApplyEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		edits []textEdit
+		want  string
+	}{
+		{
+			name:  "replace",
+			src:   "a = 1\nb = 2\n",
+			edits: []textEdit{{4, 5, "10"}},
+			want:  "a = 10\nb = 2\n",
+		},
+		{
+			name:  "unsorted replace and remove",
+			src:   "a = 1\nb = 2\nc = 3\n",
+			edits: []textEdit{{6, 12, ""}, {16, 17, "30"}, {4, 5, "10"}},
+			want:  "a = 10\nc = 30\n",
+		},
+		{
+			name:  "inserts at the same offset keep their order",
+			src:   "url\nend\n",
+			edits: mergeInserts([]textEdit{{3, 3, "\nsha256"}, {0, 3, "URL"}, {3, 3, "\nversion"}}),
+			want:  "URL\nsha256\nversion\nend\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyEdits(tt.src, tt.edits); got != tt.want {
+				t.Errorf("applyEdits() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,125 +1,241 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
 )
 
-// updateOne holt das komplette Upstream-Ruby-File (.rb), passt es minimal an dein Gov-Naming an
-// (aktuell nur die class-Zeile), zeigt eine Vorschau und schreibt es optional (apply=true)
+// updateOut bekommt alle Ausgaben des Update-Modes (Diff, Checksum, Summary, Commit/Push).
+// Normal stdout; bei --format json stderr, damit stdout ein einzelnes JSON Dokument bleibt.
+var updateOut io.Writer = os.Stdout
+
+// updateOptions bündelt die Update-Flags, die für jede Formula gleich sind.
+// - apply: false = nur anzeigen (Dry-run), true = Datei überschreiben
+// - mode: updateBump (dein File behalten, nur url/sha256/version/revision umschreiben)
+// oder updateReplace (File durch Upstream ersetzen, nur class-Zeile angepasst)
+// - patchDir: wenn gesetzt, wird der Diff zusätzlich als <patchDir>/<name>.patch geschrieben
+// - commit: geänderte Files werden danach auf einen Branch committed (impliziert apply)
+// - verifySHA256: Archiv der stable url laden, sha256 nachrechnen und gegenprüfen (checksum.go);
+// nur mit apply, der Dry-run zeigt den Upstream sha256 ohne Download
+// - maxArchiveBytes: Grössenlimit für diesen Download
+// - rules: Name-Mapping und externe Taps (Defaults + Config)
+type updateOptions struct {
+	apply           bool
+	mode            updateMode
+	patchDir        string
+	commit          bool
+	verifySHA256    bool
+	maxArchiveBytes int64
+	rules           nameRules
+}
+
+// updateOne holt das komplette Upstream-Ruby-File (.rb), erzeugt daraus die neue Version
+// deines Files, zeigt eine Vorschau und schreibt es optional (apply=true)
 // in dein lokales Mirror-Repo (.cache/private-tap/...).
 //
+// - ctx: Ctrl-C / --timeout; abgebrochen wird nur vor dem Schreiben, nie mittendrin
 // - client: wiederverwendeter HTTP Client (Timeout etc.)
-// - privateName: z.B. "gov-abseil"
-// - entry: enthält lokale Version & vor allem den Ziel-Pfad entry.Path
-// - apply: false = nur anzeigen (Dry-run), true = Datei überschreiben
-func updateOne(client *http.Client, privateName string, entry localFormula, apply bool) error {
-	// 1) Private Name -> Upstream Name mappen (z.B. gov-abseil -> abseil)
-	upName := toUpstreamName(privateName)
+// - t: Tap + Name der Formula, z.B. "gov-abseil"
+// - entry (aus t.tap): enthält lokale Version & vor allem den Ziel-Pfad entry.Path
+// - opts: apply/mode/patchDir (siehe updateOptions)
+//
+// Rückgabe: bei apply und tatsächlicher Änderung die alte/neue Version (für --commit), sonst nil.
+func updateOne(ctx context.Context, client *http.Client, t updateTarget, opts updateOptions) (*bumpedFormula, error) {
+	privateName := t.name
+	entry := t.tap.formulae[t.name]
+
+	// 1) Private Name -> Upstream Name mappen (z.B. gov-abseil -> abseil, Prefix pro Tap)
+	upName := opts.rules.toUpstreamName(privateName, entry.Prefix, kindFormula)
 
 	// 2) Komplettes Upstream .rb holen (nicht nur Version!)
 	//    rb = vollständiger Ruby-Text
 	//    srcURL = woher es genau geladen wurde
-	rb, srcURL, err := fetchUpStreamRB(client, upName)
+	rb, srcURL, err := fetchUpStreamRB(ctx, client, opts.rules, upName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 3) Upstream Ruby Text transformieren (minimal):
-	//    class Abseil < Formula  -> class GovAbseil < Formula
-	out := transformFormulaClass(rb, privateName)
+	// 3) Neuen File-Inhalt erzeugen
+	//    bump:    dein File + url/sha256/version aus upstream (siehe bumpFormula)
+	//    replace: Upstream Ruby Text minimal transformieren:
+	//             class Abseil < Formula  -> class GovAbseil < Formula
+	//    Das aktuelle File brauchen wir in beiden Modes (bump: als Basis, beide: für den Diff).
+	cur, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	var out string
+	if opts.mode == updateBump {
+		out, err = bumpFormula(string(cur), rb, privateName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", privateName, err)
+		}
+	} else {
+		out, err = transformFormulaClass(rb, privateName)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// 4) Header / Kontext ausgeben
-	fmt.Println()
-	if apply {
-		fmt.Println("=== APPLY UPDATE ===")
+	fmt.Fprintln(updateOut)
+	if opts.apply {
+		fmt.Fprintln(updateOut, "=== APPLY UPDATE ===")
 	} else {
-		fmt.Println("=== DRY-RUN UPDATE ===")
+		fmt.Fprintln(updateOut, "=== DRY-RUN UPDATE ===")
+	}
+	fmt.Fprintf(updateOut, "Private:  %s\n", t.label)    // dein Paketname im Private Tap (tap/name bei mehreren Taps)
+	fmt.Fprintf(updateOut, "Upstream: %s\n", upName)     // upstream formula name
+	fmt.Fprintf(updateOut, "Source:   %s\n", srcURL)     // URL des geladenen .rb
+	fmt.Fprintf(updateOut, "Target:   %s\n", entry.Path) // lokales Ziel-File (Mirror)
+	fmt.Fprintf(updateOut, "Mode:     %s\n", opts.mode)  // bump oder replace
+	if opts.mode == updateBump {
+		// bumpFormula lief über den Zeilen-Fallback -> Diff genau prüfen
+		_, perr := parseFormula(string(cur))
+		if perr == nil {
+			_, perr = parseFormula(rb)
+		}
+		if perr != nil {
+			fmt.Fprintf(updateOut, "Parser:   line fallback (%v)\n", perr)
+		}
 	}
-	fmt.Printf("Private:  %s\n", privateName) // dein Paketname im Private Tap
-	fmt.Printf("Upstream: %s\n", upName)      // upstream formula name
-	fmt.Printf("Source:   %s\n", srcURL)      // URL des geladenen .rb
-	fmt.Printf("Target:   %s\n", entry.Path)  // lokales Ziel-File (Mirror)
-	fmt.Println()
+	fmt.Fprintln(updateOut)
 
-	// 5) Mini-Sanity-Check: Prüfen, ob die erwartete Gov-Class Zeile im Output vorkommt
+	// 5) Mini-Sanity-Check (nur replace): Prüfen, ob die erwartete Gov-Class Zeile im Output vorkommt
 	//    (hilft dir zu sehen, ob transformFormulaClass korrekt gegriffen hat)
-	fmt.Println("Class line check:")
-	expectedLine := "class " + toGovClassName(privateName) + " < Formula"
-	fmt.Printf(" - expect: %s\n", expectedLine)
+	if opts.mode == updateReplace {
+		fmt.Fprintln(updateOut, "Class line check:")
+		expectedLine := "class " + formulaClassName(privateName) + " < Formula"
+		fmt.Fprintf(updateOut, " - expect: %s\n", expectedLine)
 
-	if !strings.Contains(out, expectedLine) {
-		fmt.Println(" - warning: Gov class line not found after transform (check regex).")
+		if !strings.Contains(out, expectedLine) {
+			fmt.Fprintln(updateOut, " - warning: Gov class line not found after transform (check class declaration).")
+		} else {
+			fmt.Fprintln(updateOut, " - ok")
+		}
+		fmt.Fprintln(updateOut)
+	}
+
+	// 6) sha256 der (neuen) stable url selbst nachrechnen und mit upstream vergleichen.
+	//    Mismatch -> Fehler, damit nie ein falscher Checksum im Tap landet.
+	//    Nur beim Schreiben: ein Dry-run lädt kein Archiv (schnell, geht auch mit --offline).
+	switch {
+	case opts.verifySHA256 && !opts.apply:
+		if sum := stableSHA256(out); sum != "" {
+			fmt.Fprintln(updateOut, "Checksum: upstream sha256", sum, "(not verified; --apply downloads the archive and checks it)")
+			fmt.Fprintln(updateOut)
+		}
+	case opts.verifySHA256:
+		checked, sum, err := verifyStableSHA256(ctx, client, out, rb, upName, opts.maxArchiveBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", privateName, err)
+		}
+		out = checked
+		if sum == "" {
+			fmt.Fprintln(updateOut, "Checksum: git url, no sha256 to verify.")
+		} else {
+			fmt.Fprintln(updateOut, "Checksum: verified sha256", sum)
+		}
+		fmt.Fprintln(updateOut)
+	}
+
+	// 7) Vorschau: Unified Diff zwischen aktuellem File und neuem Inhalt
+	//    (farbig, wenn die Ausgabe ein Terminal ist). Pfade relativ zum Tap-Root, wie bei git diff.
+	rel := repoRelPath(entry.Path)
+	diff := unifiedDiff("a/"+rel, "b/"+rel, string(cur), out)
+	if diff == "" {
+		fmt.Fprintln(updateOut, "No changes: file is already up to date.")
+	} else if isTerminal(updateOut) {
+		fmt.Fprint(updateOut, colorizeDiff(diff))
 	} else {
-		fmt.Println(" - ok")
+		fmt.Fprint(updateOut, diff)
 	}
-	fmt.Println()
 
-	// 6) Vorschau: erste 25 Zeilen anzeigen, damit du vor dem Schreiben kurz prüfen kannst
-	fmt.Println("--- Preview (first 25 lines) ---")
-	lines := strings.Split(out, "\n")
-	for i := 0; i < 25 && i < len(lines); i++ {
-		fmt.Println(lines[i])
+	//    Optional: Diff zusätzlich als <patchDir>/<name>.patch ablegen (git apply kompatibel)
+	if opts.patchDir != "" && diff != "" {
+		if err := os.MkdirAll(opts.patchDir, 0o755); err != nil {
+			return nil, err
+		}
+		patchPath := filepath.Join(opts.patchDir, filepath.FromSlash(t.label)+".patch")
+		if err := os.MkdirAll(filepath.Dir(patchPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(patchPath, []byte(diff), 0o644); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(updateOut, "Wrote patch to:", patchPath)
 	}
-	fmt.Println("--- end preview ---")
 
-	// 7) Apply-Mode: Datei wirklich überschreiben
+	// 8) Apply-Mode: Datei wirklich überschreiben
 	//    Dry-Run: nichts schreiben
-	fmt.Println()
-	if apply {
-		// Schreibzugriff ins Mirror Repo (.cache/private-tap)
+	fmt.Fprintln(updateOut)
+	if opts.apply {
+		// Schreibzugriff ins Mirror Repo (.cache/private-tap) bzw. in --tap-path
 		// Hinweis: das ist NICHT automatisch gepusht/committed, nur lokal geschrieben.
+		// Abgebrochen (Ctrl-C / --timeout)? Dann lieber gar nicht schreiben.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if err := os.WriteFile(entry.Path, []byte(out), 0o644); err != nil {
-			return err
+			return nil, err
 		}
 
-		fmt.Println("Wrote updated file to:", entry.Path)
-		fmt.Println("Next: cd .cache/private-tap && git diff")
-		fmt.Println()
+		fmt.Fprintln(updateOut, "Wrote updated file to:", entry.Path)
+		if !opts.commit {
+			fmt.Fprintf(updateOut, "Next: cd %s && git diff\n", t.tap.dir)
+		}
+		fmt.Fprintln(updateOut)
 	} else {
-		fmt.Println("Nothing was written. Run again with --apply to overwrite the file.")
-		fmt.Println()
+		fmt.Fprintln(updateOut, "Nothing was written. Run again with --apply to overwrite the file.")
+		fmt.Fprintln(updateOut)
+		return nil, nil
 	}
 
-	return nil
+	if diff == "" {
+		return nil, nil
+	}
+	newVer, _, _ := extractVersion(out, privateName)
+	return &bumpedFormula{
+		tap:         t.tap,
+		privateName: privateName,
+		upstream:    upName,
+		path:        entry.Path,
+		oldVersion:  entry.Version,
+		newVersion:  newVer,
+		sourceURL:   srcURL,
+	}, nil
 }
 
 // fetchUpStreamRB lädt das komplette Ruby-File (.rb) als Text.
 //
 // Priorität:
-// 1) Wenn upstreamName in externalTapRawRB vorkommt, lade von dort (externe Taps).
+// 1) Wenn upstreamName in rules.externalTaps vorkommt, lade von dort (externe Taps).
 // 2) Sonst: Homebrew-core raw URL generieren und laden.
-func fetchUpStreamRB(client *http.Client, upstreamName string) (content string, srcURL string, err error) {
+func fetchUpStreamRB(ctx context.Context, client *http.Client, rules nameRules, upstreamName string) (content string, srcURL string, err error) {
 	// 1) Externe Tap-Overrides (z.B. danger-js, sdkman-cli, ...)
-	if raw, ok := externalTapRawRB[upstreamName]; ok {
-		txt, err := httpGetText(client, raw)
+	if raw, ok := rules.externalTaps[upstreamName]; ok {
+		raw = endpoints.externalURL(raw)
+		txt, err := httpGetText(ctx, client, raw)
 		return txt, raw, err
 	}
 
 	// 2) Standard: homebrew-core raw URL
-	raw := buildHomebrewCoreRawURL(upstreamName)
-	txt, err := httpGetText(client, raw)
+	raw := endpoints.coreRawURL(upstreamName)
+	txt, err := httpGetText(ctx, client, raw)
 	return txt, raw, err
 }
 
-// buildHomebrewCoreRawURL baut die Raw-URL für homebrew-core.
-// homebrew-core Struktur: Formula/<first-letter>/<name>.rb
-func buildHomebrewCoreRawURL(name string) string {
-	first := name[:1]
-	return "https://raw.githubusercontent.com/Homebrew/homebrew-core/refs/heads/master/Formula/" +
-		first + "/" + name + ".rb"
-}
-
 // httpGetText macht einen HTTP GET und gibt den Response Body als string zurück.
 // - prüft Status Codes (404 -> not found, andere non-2xx -> Fehler)
 // - liest gesamten Body in memory (bei .rb ok)
-func httpGetText(client *http.Client, url string) (string, error) {
+func httpGetText(ctx context.Context, client *http.Client, url string) (string, error) {
 	// HTTP Request
-	resp, err := client.Get(url)
+	resp, err := httpGet(ctx, client, url)
 	if err != nil {
 		return "", err
 	}
@@ -143,43 +259,53 @@ func httpGetText(client *http.Client, url string) (string, error) {
 	return string(b), nil
 }
 
-// transformFormulaClass ersetzt die class-Zeile im Upstream File durch deine Gov-Class.
+// transformFormulaClass ersetzt den Klassennamen im Upstream File durch deine Gov-Class.
 // Beispiel:
 //
 //	class Abseil < Formula
 //
 // -> class GovAbseil < Formula
 //
-// Regex ist bewusst tolerant: matcht "class <word> < Formula" plus evtl. trailing stuff.
-func transformFormulaClass(rb string, privateName string) string {
-	govClass := toGovClassName(privateName)
+// Die Klassen-Deklaration kommt aus dem Mini-Parser (rubydsl.go); nur der
+// Name wird ersetzt, der Rest des Files bleibt byte-genau gleich.
+func transformFormulaClass(rb string, privateName string) (string, error) {
+	doc, err := parseFormula(rb)
+	if err != nil {
+		return "", fmt.Errorf("parse upstream formula: %w", err)
+	}
+	if doc.parent != "Formula" {
+		return "", fmt.Errorf("class %s is not a Formula (parent %q)", doc.className.text, doc.parent)
+	}
 
-	// ^class <Name> < Formula ... (ganze Zeile)
-	re := regexp.MustCompile(`(?m)^\s*class\s+\w+\s+<\s+Formula\b.*$`)
-	return re.ReplaceAllString(rb, "class "+govClass+" < Formula")
+	return rb[:doc.className.start] + formulaClassName(privateName) + rb[doc.className.end:], nil
 }
 
-// toGovClassName macht aus "gov-abseil" -> "GovAbseil"
-// und aus "gov-git-filter-repo" -> "GovGitFilterRepo"
-func toGovClassName(privateName string) string {
-	// gov- prefix entfernen
-	s := strings.TrimPrefix(privateName, "gov-")
-
-	// in Teile splitten (an "-")
-	parts := strings.Split(s, "-")
-
+// formulaClassName liefert den Ruby Klassennamen, den Homebrew für einen Formula-Namen erwartet
+// (wie Formulary.class_s):
+//
+//	gov-abseil          -> GovAbseil
+//	gov-git-filter-repo -> GovGitFilterRepo
+//	gov-llvm@13         -> GovLlvmAT13
+func formulaClassName(name string) string {
 	var b strings.Builder
-	b.WriteString("Gov")
-
-	// jedes Teil in CamelCase
-	for _, p := range parts {
-		if p == "" {
+	upper := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '-' || c == '_' || c == '.' || c == ' ':
+			upper = true
 			continue
+		case c == '@' && i+1 < len(name) && name[i+1] >= '0' && name[i+1] <= '9':
+			b.WriteString("AT")
+			continue
+		case c == '+':
+			b.WriteByte('x')
+		case upper && c >= 'a' && c <= 'z':
+			b.WriteByte(c - 'a' + 'A')
+		default:
+			b.WriteByte(c)
 		}
-		b.WriteString(strings.ToUpper(p[:1]))
-		if len(p) > 1 {
-			b.WriteString(p[1:])
-		}
+		upper = false
 	}
 	return b.String()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,21 +17,35 @@ const (
 	kindCask
 )
 
-type tapEntry struct {
-	name    string
-	Version string
-	Path    string
-	Kind    kind
+// String liefert den Namen der Art, wie er im Report erscheint.
+func (k kind) String() string {
+	switch k {
+	case kindFormula:
+		return "formula"
+	case kindCask:
+		return "cask"
+	default:
+		return "unknown"
+	}
 }
 
-var upstreamOverrides = map[string]string{
+// defaultOverrides: privater Name -> upstream Name, wenn der Prefix allein nicht reicht.
+// Eingebaute Defaults; die Config (overrides) ergänzt/überschreibt sie (auditConfig.nameRules).
+var defaultOverrides = map[string]string{
 	"gov-filter-repo":        "git-filter-repo",
 	"gov-md2man":             "go-md2man",
 	"gov-swift-package-list": "swift-package-list",
 	"gov-shebang-probe":      "scriptisto",
 }
 
-var externalTapRawRB = map[string]string{
+// prefixRule ersetzt einen Prefix des privaten Namens, z.B. "gov-py-" -> "python-".
+type prefixRule struct {
+	prefix  string
+	replace string
+}
+
+var defaultExternalTaps = map[string]string{
+	// Eingebaute Defaults; die Config (external_taps) ergänzt/überschreibt sie (auditConfig.nameRules).
 	// Formulae, die NICHT in homebrew/core sind, aber in bekannten Taps liegen:
 	// Key = upstream formula name (ohne gov- Prefix, ohne @patch)
 	// Value = raw URL zur .rb
@@ -42,24 +57,87 @@ var externalTapRawRB = map[string]string{
 	"swift-package-list": "https://raw.githubusercontent.com/FelixHerrmann/homebrew-tap/master/Formula/swift-package-list.rb",
 }
 
+// nameRules sind die Regeln für private Name -> upstream Name plus die externen Taps.
+// Ein Wert statt globaler Maps: Vergleich, Update und Tests sehen genau die Regeln,
+// die ihnen übergeben werden (Defaults + Config, siehe auditConfig.nameRules).
+type nameRules struct {
+	overrides     map[string]string // privater Formula Name -> upstream Name
+	caskOverrides map[string]string // privater Cask Name -> upstream Token (eigene Map, Casks haben andere Namen)
+	prefixRules   []prefixRule      // aus der Config (prefix_rules), längster Prefix zuerst
+	externalTaps  map[string]string // upstream Formula Name -> raw URL zur .rb
+}
+
 // ---- API Response Struct ----
 type formulaAPIResponse struct {
 	Name     string `json:"name"`
 	Versions struct {
 		Stable string `json:"stable"`
 	} `json:"versions"`
+	URLs struct {
+		Stable struct {
+			URL      string `json:"url"`
+			Checksum string `json:"checksum"` // sha256 des Archivs (leer bei git urls)
+		} `json:"stable"`
+	} `json:"urls"`
+}
+
+// ---- API Response Struct (Casks) ----
+type caskAPIResponse struct {
+	Token   string `json:"token"`
+	Version string `json:"version"`
+}
+
+// httpStatusError ist ein non-2xx Status bei einem Upstream Request.
+// Als eigener Typ, damit der Report (z.B. JSON) den Status Code strukturiert ausgeben kann.
+type httpStatusError struct {
+	what   string // wer hat angefragt, z.B. "upstream" oder "tap raw"
+	url    string
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s http status %d", e.what, e.status)
+}
+
+// httpGet ist client.Get mit ctx: Ctrl-C bzw. --timeout brechen den Request (und Retries) ab.
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
 }
 
 // ---- Mapping: private name -> upstream name ----
-// Start simpel: gov-foo -> foo
-func toUpstreamName(private string) string {
-	s := strings.TrimPrefix(private, "gov-")
+// Reihenfolge für Formulae:
+// 1) overrides (exakter privater Name)
+// 2) prefixRules (erster passender, d.h. längster Prefix wird ersetzt)
+// 3) sonst: Tap-Prefix entfernen, gov-foo -> foo (prefix kommt pro Tap aus der Config, Default "gov-")
+// Danach wird ein @patch Suffix gekürzt (gov-foo@1.2.3 -> foo, gov-foo@13 bleibt foo@13).
+//
+// Casks: nur caskOverrides, sonst Tap-Prefix entfernen. Formula overrides und prefixRules
+// gelten nicht, und @ bleibt stehen (firefox@esr, temurin@21 sind eigene Cask Tokens).
+func (r nameRules) toUpstreamName(private, prefix string, k kind) string {
+	if k == kindCask {
+		if v, ok := r.caskOverrides[private]; ok {
+			return v
+		}
+		return strings.TrimPrefix(private, prefix)
+	}
 
 	// Overrides
-	if v, ok := upstreamOverrides[private]; ok {
+	if v, ok := r.overrides[private]; ok {
 		return v
 	}
 
+	s := strings.TrimPrefix(private, prefix)
+	for _, p := range r.prefixRules {
+		if strings.HasPrefix(private, p.prefix) {
+			s = p.replace + strings.TrimPrefix(private, p.prefix)
+			break
+		}
+	}
+
 	at := strings.LastIndex(s, "@")
 	if at == -1 {
 		return s
@@ -99,10 +177,10 @@ func allDigits(s string) bool {
 }
 
 // ---- Upstream stable Version via API holen ----
-func fetchUpstreamStable(client *http.Client, formula string) (stable string, ok bool, err error) {
-	url := "https://formulae.brew.sh/api/formula/" + formula + ".json"
+func fetchUpstreamStable(ctx context.Context, client *http.Client, rules nameRules, formula string) (stable string, ok bool, err error) {
+	url := endpoints.formulaURL(formula)
 
-	resp, err := client.Get(url)
+	resp, err := httpGet(ctx, client, url)
 	if err != nil {
 		return "", false, err
 	}
@@ -113,41 +191,10 @@ func fetchUpstreamStable(client *http.Client, formula string) (stable string, ok
 	}()
 
 	if resp.StatusCode == http.StatusNotFound {
-		rawURL, ok2 := externalTapRawRB[formula]
-		if !ok2 {
-			return "", false, nil
-		}
-
-		r2, err := client.Get(rawURL)
-		if err != nil {
-			return "", false, err
-		}
-		defer func() {
-			if cerr := r2.Body.Close(); cerr != nil {
-				// optional: log.Printf("close resp body: %v", cerr)
-			}
-		}()
-
-		if r2.StatusCode == http.StatusNotFound {
-			return "", false, nil
-		}
-		if r2.StatusCode < 200 || r2.StatusCode >= 300 {
-			return "", false, fmt.Errorf("tap raw http status %d", r2.StatusCode)
-		}
-
-		body, err := io.ReadAll(r2.Body)
-		if err != nil {
-			return "", false, err
-		}
-
-		v := strings.TrimSpace(extractVersion(string(body), formula))
-		if v == "" {
-			return "", false, nil
-		}
-		return v, true, nil
+		return fetchExternalTapStable(ctx, client, rules, formula)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", false, fmt.Errorf("upstream http status %d", resp.StatusCode)
+		return "", false, &httpStatusError{what: "upstream", url: url, status: resp.StatusCode}
 	}
 
 	var data formulaAPIResponse
@@ -161,3 +208,73 @@ func fetchUpstreamStable(client *http.Client, formula string) (stable string, ok
 	}
 	return stable, true, nil
 }
+
+// ---- Fallback: Version aus dem .rb eines externen Taps lesen ----
+// Nur für Formulae, die in rules.externalTaps eingetragen sind; alle anderen gelten als nicht gefunden.
+func fetchExternalTapStable(ctx context.Context, client *http.Client, rules nameRules, formula string) (stable string, ok bool, err error) {
+	rawURL, ok := rules.externalTaps[formula]
+	if !ok {
+		return "", false, nil
+	}
+	rawURL = endpoints.externalURL(rawURL)
+
+	r2, err := httpGet(ctx, client, rawURL)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if cerr := r2.Body.Close(); cerr != nil {
+			// optional: log.Printf("close resp body: %v", cerr)
+		}
+	}()
+
+	if r2.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if r2.StatusCode < 200 || r2.StatusCode >= 300 {
+		return "", false, &httpStatusError{what: "tap raw", url: rawURL, status: r2.StatusCode}
+	}
+
+	body, err := io.ReadAll(r2.Body)
+	if err != nil {
+		return "", false, err
+	}
+
+	v, _, _ := extractVersion(string(body), formula)
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", false, nil
+	}
+	return v, true, nil
+}
+
+// ---- Upstream Cask Version via API holen ----
+// Casks haben keine "stable" Sektion, sondern direkt ein version Feld (z.B. "128.0" oder "1.2,345").
+// "latest" ist keine vergleichbare Version und gilt darum als nicht gefunden.
+func fetchUpstreamCask(ctx context.Context, client *http.Client, token string) (version string, ok bool, err error) {
+	url := endpoints.caskURL(token)
+
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", false, &httpStatusError{what: "upstream cask", url: url, status: resp.StatusCode}
+	}
+
+	var data caskAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", false, err
+	}
+
+	version = strings.TrimSpace(data.Version)
+	if version == "" || version == "latest" {
+		return "", false, nil
+	}
+	return version, true, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// formulaIndexEntry ist der Teil eines formula.json Eintrags, den wir brauchen.
+type formulaIndexEntry struct {
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases"`
+	OldNames []string `json:"oldnames"`
+	Versions struct {
+		Stable string `json:"stable"`
+	} `json:"versions"`
+}
+
+// caskIndexEntry ist der Teil eines cask.json Eintrags, den wir brauchen.
+type caskIndexEntry struct {
+	Token     string   `json:"token"`
+	OldTokens []string `json:"old_tokens"`
+	Version   string   `json:"version"`
+}
+
+// upstreamIndex hält die stable Versionen aller homebrew/core Formulae im Speicher.
+// Key = Formula-Name, Alias oder alter Name; Value = stable Version.
+//
+// casks ist analog für homebrew/cask (Key = Token oder alter Token) und bleibt nil,
+// solange der Cask Index nicht geladen wurde.
+type upstreamIndex struct {
+	formulae map[string]string
+	casks    map[string]string
+}
+
+// loadUpstreamIndex lädt formula.json einmal und baut daraus den Index.
+//
+// Aliases und oldnames werden ebenfalls eingetragen, aber ein echter
+// Formula-Name hat immer Vorrang (ein Alias überschreibt nie einen Namen).
+func loadUpstreamIndex(ctx context.Context, client *http.Client) (*upstreamIndex, error) {
+	// Bulk-Export aller homebrew/core Formulae: ersetzt hunderte Requests auf api/formula/<name>.json
+	indexURL := endpoints.formulaIndexURL()
+	resp, err := httpGet(ctx, client, indexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &httpStatusError{what: "formula index", url: indexURL, status: resp.StatusCode}
+	}
+
+	var entries []formulaIndexEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode formula index: %w", err)
+	}
+
+	idx := &upstreamIndex{formulae: make(map[string]string, len(entries)*2)}
+
+	// 1) echte Namen
+	for _, e := range entries {
+		idx.formulae[e.Name] = strings.TrimSpace(e.Versions.Stable)
+	}
+
+	// 2) Aliases / oldnames nur, wenn der Key noch frei ist
+	for _, e := range entries {
+		for _, alt := range append(e.Aliases, e.OldNames...) {
+			if _, taken := idx.formulae[alt]; !taken {
+				idx.formulae[alt] = strings.TrimSpace(e.Versions.Stable)
+			}
+		}
+	}
+
+	return idx, nil
+}
+
+// loadCaskIndex lädt cask.json einmal und trägt die Versionen in idx.casks ein.
+// Bei einem Fehler bleibt idx.casks nil, damit Cask Lookups auf Einzel-Requests zurückfallen.
+func loadCaskIndex(ctx context.Context, client *http.Client, idx *upstreamIndex) error {
+	indexURL := endpoints.caskIndexURL()
+	resp, err := httpGet(ctx, client, indexURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{what: "cask index", url: indexURL, status: resp.StatusCode}
+	}
+
+	var entries []caskIndexEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return fmt.Errorf("decode cask index: %w", err)
+	}
+
+	casks := make(map[string]string, len(entries))
+	for _, e := range entries {
+		casks[e.Token] = strings.TrimSpace(e.Version)
+	}
+	for _, e := range entries {
+		for _, old := range e.OldTokens {
+			if _, taken := casks[old]; !taken {
+				casks[old] = strings.TrimSpace(e.Version)
+			}
+		}
+	}
+
+	idx.casks = casks
+	return nil
+}
+
+// lookupUpstreamStable beantwortet einen Lookup aus dem Index, falls vorhanden.
+//
+// - idx == nil: Index nicht verfügbar -> klassischer Request pro Formula (fetchUpstreamStable)
+// - Treffer im Index: Version direkt aus dem Speicher
+// - kein Treffer: Formula ist nicht in homebrew/core -> nur noch externe Taps prüfen
+func lookupUpstreamStable(ctx context.Context, client *http.Client, idx *upstreamIndex, rules nameRules, formula string) (stable string, ok bool, err error) {
+	if idx == nil {
+		return fetchUpstreamStable(ctx, client, rules, formula)
+	}
+
+	if v, found := idx.formulae[formula]; found {
+		if v == "" {
+			return "", false, nil
+		}
+		return v, true, nil
+	}
+
+	return fetchExternalTapStable(ctx, client, rules, formula)
+}
+
+// lookupUpstreamCask ist das Cask-Gegenstück zu lookupUpstreamStable.
+// Ohne geladenen Cask Index geht jeder Lookup an api/cask/<token>.json.
+func lookupUpstreamCask(ctx context.Context, client *http.Client, idx *upstreamIndex, token string) (version string, ok bool, err error) {
+	if idx == nil || idx.casks == nil {
+		return fetchUpstreamCask(ctx, client, token)
+	}
+
+	v, found := idx.casks[token]
+	if !found || v == "" || v == "latest" {
+		return "", false, nil
+	}
+	return v, true, nil
+}
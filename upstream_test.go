@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+// TestToUpstreamName: Overrides, Prefix-Regeln und @patch Kürzung, Defaults plus Config;
+// Casks nur mit cask_overrides und ohne Prefix-Regeln.
+func TestToUpstreamName(t *testing.T) {
+	rules := auditConfig{
+		Overrides:     map[string]string{"gov-mytool": "tool", "gov-md2man": "md2man-fork"},
+		CaskOverrides: map[string]string{"gov-chrome": "google-chrome"},
+		PrefixRules:   map[string]string{"gov-py-": "python-", "gov-py-legacy-": "py2-"},
+	}.nameRules()
+
+	tests := []struct {
+		name    string
+		private string
+		prefix  string
+		kind    kind
+		want    string
+	}{
+		{name: "plain prefix", private: "gov-abseil", prefix: "gov-", kind: kindFormula, want: "abseil"},
+		{name: "other tap prefix", private: "corp-abseil", prefix: "corp-", kind: kindFormula, want: "abseil"},
+		{name: "built-in override", private: "gov-filter-repo", prefix: "gov-", kind: kindFormula, want: "git-filter-repo"},
+		{name: "config override", private: "gov-mytool", prefix: "gov-", kind: kindFormula, want: "tool"},
+		{name: "config wins over built-in", private: "gov-md2man", prefix: "gov-", kind: kindFormula, want: "md2man-fork"},
+		{name: "prefix rule", private: "gov-py-requests", prefix: "gov-", kind: kindFormula, want: "python-requests"},
+		{name: "longest prefix rule wins", private: "gov-py-legacy-six", prefix: "gov-", kind: kindFormula, want: "py2-six"},
+		{name: "patch suffix dropped", private: "gov-foo@1.2.3", prefix: "gov-", kind: kindFormula, want: "foo"},
+		{name: "major suffix kept", private: "gov-llvm@15", prefix: "gov-", kind: kindFormula, want: "llvm@15"},
+		{name: "major.minor suffix kept", private: "gov-python@3.12", prefix: "gov-", kind: kindFormula, want: "python@3.12"},
+		{name: "prefix rule with patch suffix", private: "gov-py-six@1.16.0", prefix: "gov-", kind: kindFormula, want: "python-six"},
+		{name: "cask prefix", private: "gov-firefox", prefix: "gov-", kind: kindCask, want: "firefox"},
+		{name: "cask override", private: "gov-chrome", prefix: "gov-", kind: kindCask, want: "google-chrome"},
+		{name: "formula override not for casks", private: "gov-mytool", prefix: "gov-", kind: kindCask, want: "mytool"},
+		{name: "cask override not for formulae", private: "gov-chrome", prefix: "gov-", kind: kindFormula, want: "chrome"},
+		{name: "prefix rule not for casks", private: "gov-py-app", prefix: "gov-", kind: kindCask, want: "py-app"},
+		{name: "cask @ token kept", private: "gov-firefox@esr", prefix: "gov-", kind: kindCask, want: "firefox@esr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.toUpstreamName(tt.private, tt.prefix, tt.kind); got != tt.want {
+				t.Errorf("toUpstreamName(%q, %q, %v) = %q, want %q", tt.private, tt.prefix, tt.kind, got, tt.want)
+			}
+		})
+	}
+
+	// Die Config darf die eingebauten Defaults nicht verändern
+	if got := (auditConfig{}).nameRules().toUpstreamName("gov-md2man", "gov-", kindFormula); got != "go-md2man" {
+		t.Errorf("defaults changed by config: gov-md2man -> %q, want go-md2man", got)
+	}
+}